@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Swarmind/libagent/pkg/agent/conversation"
 	"github.com/Swarmind/libagent/pkg/agent/generic"
 	"github.com/Swarmind/libagent/pkg/config"
 	"github.com/Swarmind/libagent/pkg/tools"
@@ -52,6 +53,17 @@ func main() {
 	}
 	agent.LLM = llm
 
+	// Keeps the history between the runs if LIBAGENT_CONVERSATION_MEMORY is set
+	conversationMemory, err := conversation.NewMemory(ctx, cfg, llm)
+	if err != nil {
+		log.Fatal().Err(err).Msg("new conversation memory")
+	}
+	if conversationMemory != nil {
+		agent.Conversation = conversationMemory
+		agent.SessionID = "generic-demo"
+		defer conversationMemory.Close()
+	}
+
 	toolsExecutor, err := tools.NewToolsExecutor(ctx, cfg, tools.WithToolsWhitelist(
 		tools.ReWOOToolDefinition.Name,
 		tools.SemanticSearchDefinition.Name,
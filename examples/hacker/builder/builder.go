@@ -18,9 +18,9 @@ import (
   This example shows usage of command executor to create a binary with generated code.
 */
 
-const BuilderPrompt = `Here is the step by step actions plan, use the command executor tool to create the binary at the end:
+const BuilderPrompt = `Here is the step by step actions plan, use the writeFile and command executor tools to create the binary at the end:
 	- Generate a simple go hello world code
-	- Write it to the file (use echo -e or printf with single quotes since echo in bash did not interpret escaped symbols by default, and there is multiple symbols needed to be escaped using single quotes as well)
+	- Write it to the main.go file with the writeFile tool
 	- Initialize go module
 	- Build it
 	- Use pwd to get the working directory
@@ -44,22 +44,25 @@ func main() {
 		"Agent-Call-ID": uuid.New().String(),
 	}
 
+	// Keep the workspace directory with the built binary after the cleanup
+	cfg.CommandExecutorWorkspaceKeep = true
+
 	ctx := context.Background()
 
 	toolsExecutor, err := tools.NewToolsExecutor(ctx, cfg, tools.WithToolsWhitelist(
 		tools.ReWOOToolDefinition.Name,
 		tools.CommandExecutorDefinition.Name,
+		tools.WriteFileDefinition.Name,
 	))
 	if err != nil {
 		log.Fatal().Err(err).Msg("new tools executor")
 	}
 
-	// Do not cleanup to use the final result
-	// defer func() {
-	// 	if err := toolsExecutor.Cleanup(); err != nil {
-	// 		log.Fatal().Err(err).Msg("tools executor cleanup")
-	// 	}
-	// }()
+	defer func() {
+		if err := toolsExecutor.Cleanup(); err != nil {
+			log.Fatal().Err(err).Msg("tools executor cleanup")
+		}
+	}()
 
 	rewooQuery := tools.ReWOOToolArgs{
 		Query: BuilderPrompt,
@@ -78,4 +81,22 @@ func main() {
 	}
 
 	fmt.Println(result)
+
+	listArtifacts := tools.CommandExecutorArgs{
+		Action: tools.CommandActionListArtifacts,
+	}
+	listArtifactsBytes, err := json.Marshal(listArtifacts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("json marhsal listArtifacts")
+	}
+
+	artifacts, err := toolsExecutor.CallTool(ctx,
+		tools.CommandExecutorDefinition.Name,
+		string(listArtifactsBytes),
+	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("list artifacts tool call")
+	}
+
+	fmt.Println(artifacts)
 }
@@ -42,6 +42,12 @@ func main() {
 		"Agent-Call-ID": uuid.New().String(),
 	}
 
+	// Record the command executor sessions for the engagement audit trail,
+	// the transcript paths are printed on cleanup
+	if cfg.CommandExecutorTranscriptDir == "" {
+		cfg.CommandExecutorTranscriptDir = "transcripts"
+	}
+
 	ctx := context.Background()
 
 	toolsExecutor, err := tools.NewToolsExecutor(ctx, cfg, tools.WithToolsWhitelist(
@@ -55,6 +61,16 @@ func main() {
 		log.Fatal().Err(err).Msg("new tools executor")
 	}
 	defer func() {
+		if commandExecutor := tools.CommandExecutor(toolsExecutor); commandExecutor != nil {
+			transcripts, err := commandExecutor.CleanupTranscripts()
+			if err != nil {
+				log.Error().Err(err).Msg("command executor cleanup")
+			}
+			for _, transcript := range transcripts {
+				fmt.Printf("session %s transcript: %s, log: %s\n",
+					transcript.Session, transcript.CastPath, transcript.LogPath)
+			}
+		}
 		if err := toolsExecutor.Cleanup(); err != nil {
 			log.Fatal().Err(err).Msg("tools executor cleanup")
 		}
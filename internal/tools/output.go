@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultOutputStoreSize is the number of the full outputs kept by the store.
+const DefaultOutputStoreSize = 64
+
+// OutputStore keeps the full outputs of the truncated tool results under the handles,
+// dropping the oldest ones when the store size is exceeded.
+type OutputStore struct {
+	Size int
+
+	mu      sync.Mutex
+	outputs map[string]string
+	order   []string
+	seq     int
+}
+
+// Put stores the output and returns its handle.
+func (s *OutputStore) Put(output string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outputs == nil {
+		s.outputs = map[string]string{}
+	}
+	s.seq++
+	handle := fmt.Sprintf("output-%d", s.seq)
+	s.outputs[handle] = output
+	s.order = append(s.order, handle)
+
+	size := s.Size
+	if size <= 0 {
+		size = DefaultOutputStoreSize
+	}
+	for len(s.order) > size {
+		delete(s.outputs, s.order[0])
+		s.order = s.order[1:]
+	}
+	return handle
+}
+
+// Get returns the stored output by its handle.
+func (s *OutputStore) Get(handle string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	output, ok := s.outputs[handle]
+	return output, ok
+}
+
+// LimitOutput cuts the middle of the output longer than limit bytes, keeping its head and tail
+// around the visible omission marker with the optional hint. The cuts are moved to the line ends when close.
+// Zero limit disables the truncation.
+func LimitOutput(output string, limit int, hint string) (string, bool) {
+	if limit <= 0 || len(output) <= limit {
+		return output, false
+	}
+
+	head := validPrefix(output, limit/2)
+	// Prefer the whole lines if the cut is not too far from the limit
+	if idx := strings.LastIndexByte(head, '\n'); idx >= len(head)/2 {
+		head = head[:idx+1]
+	}
+	tail := validSuffix(output, limit-len(head))
+	if idx := strings.IndexByte(tail, '\n'); idx != -1 && idx < len(tail)/2 {
+		tail = tail[idx+1:]
+	}
+	omitted := len(output) - len(head) - len(tail)
+	return OmitOutput(head, tail, omitted, hint), true
+}
+
+// OmitOutput joins the output head and tail around the visible marker of the omitted bytes with the optional hint.
+func OmitOutput(head, tail string, omitted int, hint string) string {
+	marker := fmt.Sprintf("... [%d bytes omitted", omitted)
+	if !strings.HasSuffix(head, "\n") {
+		marker = "\n" + marker
+	}
+	if hint != "" {
+		marker += ", " + hint
+	}
+	marker += "] ...\n"
+	return head + marker + tail
+}
+
+// validPrefix returns up to n bytes of the string head, not splitting the runes.
+func validPrefix(s string, n int) string {
+	n = min(n, len(s))
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// validSuffix returns up to n bytes of the string tail, not splitting the runes.
+func validSuffix(s string, n int) string {
+	start := max(len(s)-n, 0)
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+// OutputPage returns up to length bytes of the output from the offset, aligned to the rune boundaries,
+// and the offset of the next page or -1 if it is the last one.
+func OutputPage(output string, offset, length int) (string, int) {
+	if offset < 0 || offset >= len(output) {
+		return "", -1
+	}
+	for offset > 0 && !utf8.RuneStart(output[offset]) {
+		offset--
+	}
+	page := validPrefix(output[offset:], length)
+	if page == "" && length > 0 {
+		// The length is shorter than the rune at the offset
+		_, size := utf8.DecodeRuneInString(output[offset:])
+		page = output[offset : offset+size]
+	}
+
+	next := offset + len(page)
+	if next >= len(output) {
+		next = -1
+	}
+	return page, next
+}
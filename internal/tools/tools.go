@@ -18,14 +18,25 @@ world knowledge and common sense. Prioritize it when you are confident in solvin
 yourself. Input can be any instruction or task.`,
 }
 
+// ReadToolOutputName is the tool used to read the full output of the truncated tool results.
+const ReadToolOutputName = "readToolOutput"
+
 type ToolData struct {
 	Definition llms.FunctionDefinition
 	Call       func(context.Context, string) (string, error)
 	Cleanup    func() error
+	// OutputLimit is the maximum size in bytes of the call result, zero means unlimited
+	OutputLimit int
+	// Tool is the tool implementation, for the callers using its own API
+	Tool any
 }
 
 type ToolsExecutor struct {
 	Tools map[string]*ToolData
+	// Outputs keeps the full results truncated by the tools output limit
+	Outputs *OutputStore
+	// SharedCleanup closes the resources shared by the tools, run by Cleanup after the tools cleanup
+	SharedCleanup []func() error
 }
 
 func (e ToolsExecutor) Execute(ctx context.Context, call llms.ToolCall) (llms.ToolCallResponse, error) {
@@ -60,7 +71,27 @@ func (e ToolsExecutor) CallTool(ctx context.Context, toolName, args string) (str
 		return "", err
 	}
 
-	return toolData.Call(ctx, args)
+	result, err := toolData.Call(ctx, args)
+	if err != nil {
+		return result, err
+	}
+	return e.limitOutput(toolName, result, toolData.OutputLimit), nil
+}
+
+// limitOutput truncates the tool result to the limit, storing the full one to read it by pages.
+func (e ToolsExecutor) limitOutput(toolName, result string, limit int) string {
+	if limit <= 0 || len(result) <= limit {
+		return result
+	}
+
+	hint := ""
+	if e.Outputs != nil {
+		handle := e.Outputs.Put(result)
+		hint = fmt.Sprintf("use %s with handle %s to read the full output", ReadToolOutputName, handle)
+	}
+	limited, _ := LimitOutput(result, limit, hint)
+	log.Debug().Msgf("Tool %s output truncated from %d to %d bytes", toolName, len(result), limit)
+	return limited
 }
 
 func (e ToolsExecutor) ToolsList() []llms.Tool {
@@ -143,5 +174,10 @@ func (e ToolsExecutor) Cleanup() error {
 			return err
 		}
 	}
+	for _, cleanup := range e.SharedCleanup {
+		if err := cleanup(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,164 @@
+package webreader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultMaxPages is the maximum number of pages rendered concurrently by the browser pool.
+	DefaultMaxPages = 4
+	// DefaultBrowserIdleTimeout is the time after the last page is closed, when the browser is shut down.
+	DefaultBrowserIdleTimeout = time.Minute
+)
+
+// BrowserPool shares one headless browser between the JS page renders.
+// The browser is launched on the first page and shut down after the idle timeout or on Close.
+type BrowserPool struct {
+	IdleTimeout time.Duration
+
+	// pages limits the number of the concurrently opened pages
+	pages chan struct{}
+
+	mu       sync.Mutex
+	browser  *rod.Browser
+	launcher *launcher.Launcher
+	active   int
+	idle     *time.Timer
+	closed   bool
+}
+
+// NewBrowserPool returns the pool rendering at most maxPages pages at once,
+// zero values are replaced with the defaults.
+func NewBrowserPool(maxPages int, idleTimeout time.Duration) *BrowserPool {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultBrowserIdleTimeout
+	}
+	return &BrowserPool{
+		IdleTimeout: idleTimeout,
+		pages:       make(chan struct{}, maxPages),
+	}
+}
+
+// Page opens a blank page bound to the context, waiting for a free slot if all of them are taken.
+// The returned release function closes the page and must be called once the page is not needed.
+func (p *BrowserPool) Page(ctx context.Context) (*rod.Page, func(), error) {
+	select {
+	case p.pages <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	browser, err := p.acquire(ctx)
+	if err != nil {
+		<-p.pages
+		return nil, nil, err
+	}
+
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		p.release()
+		<-p.pages
+		return nil, nil, fmt.Errorf("open page: %w", err)
+	}
+
+	var once sync.Once
+	return page.Context(ctx), func() {
+		once.Do(func() {
+			if err := page.Close(); err != nil {
+				log.Debug().Err(err).Msg("webreader close page")
+			}
+			p.release()
+			<-p.pages
+		})
+	}, nil
+}
+
+// acquire returns the running browser, launching it if needed, and marks it used.
+func (p *BrowserPool) acquire(ctx context.Context) (*rod.Browser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("browser pool closed")
+	}
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+	if p.browser == nil {
+		l := launcher.New().Context(ctx)
+		u, err := l.Launch()
+		if err != nil {
+			return nil, fmt.Errorf("launch browser: %w", err)
+		}
+		browser := rod.New().ControlURL(u)
+		if err := browser.Connect(); err != nil {
+			l.Kill()
+			return nil, fmt.Errorf("connect browser: %w", err)
+		}
+		log.Debug().Msgf("webreader browser started, pid %d", l.PID())
+		p.browser, p.launcher = browser, l
+	}
+	p.active++
+	return p.browser, nil
+}
+
+// release marks the page closed, scheduling the browser shutdown when no pages are left.
+func (p *BrowserPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active--
+	if p.active > 0 || p.closed || p.browser == nil {
+		return
+	}
+	browser := p.browser
+	p.idle = time.AfterFunc(p.IdleTimeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.active > 0 || p.browser != browser {
+			return
+		}
+		log.Debug().Msg("webreader browser idle shutdown")
+		p.shutdown()
+	})
+}
+
+// Close shuts down the browser, the pool can not be used afterwards.
+func (p *BrowserPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+	return p.shutdown()
+}
+
+// shutdown closes the browser and removes its profile, called with mu held.
+func (p *BrowserPool) shutdown() error {
+	if p.browser == nil {
+		return nil
+	}
+	err := p.browser.Close()
+	p.launcher.Kill()
+	p.launcher.Cleanup()
+	p.browser, p.launcher = nil, nil
+	return err
+}
@@ -0,0 +1,139 @@
+package webreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// skipWithoutBrowser skips the test if no local browser is installed, the pool would download it otherwise.
+func skipWithoutBrowser(t *testing.T) {
+	t.Helper()
+	if _, ok := launcher.LookPath(); !ok {
+		t.Skip("browser is not installed")
+	}
+}
+
+// running reports if the pool browser is launched.
+func (p *BrowserPool) running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.browser != nil
+}
+
+func TestBrowserPoolPageCap(t *testing.T) {
+	pool := NewBrowserPool(2, 0)
+	defer pool.Close()
+	// The taken slots make Page wait before launching the browser
+	pool.pages <- struct{}{}
+	pool.pages <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := pool.Page(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want to wait for a free slot", err)
+	}
+	if pool.running() {
+		t.Errorf("browser launched while all the slots are taken")
+	}
+}
+
+func TestBrowserPoolClosed(t *testing.T) {
+	pool := NewBrowserPool(0, 0)
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	if _, _, err := pool.Page(context.Background()); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("error %v, want the closed pool error", err)
+	}
+	if n := len(pool.pages); n != 0 {
+		t.Errorf("%d slots taken after the failed page", n)
+	}
+}
+
+func TestBrowserPoolPages(t *testing.T) {
+	skipWithoutBrowser(t)
+
+	pool := NewBrowserPool(1, 100*time.Millisecond)
+	defer pool.Close()
+
+	_, release, err := pool.Page(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := pool.Page(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want to wait for the page over the cap", err)
+	}
+
+	release()
+	release() // repeated release frees the slot once
+	if !pool.running() {
+		t.Fatalf("browser shut down before the idle timeout")
+	}
+	_, release, err = pool.Page(context.Background())
+	if err != nil {
+		t.Fatalf("page after release: %v", err)
+	}
+	release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.running() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pool.running() {
+		t.Errorf("browser is running after the idle timeout")
+	}
+}
+
+func TestBrowserPoolClose(t *testing.T) {
+	skipWithoutBrowser(t)
+
+	pool := NewBrowserPool(0, time.Hour)
+	_, release, err := pool.Page(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	release()
+	if pool.running() {
+		t.Errorf("browser is running after close")
+	}
+	if _, _, err := pool.Page(context.Background()); err == nil {
+		t.Errorf("page opened after close")
+	}
+}
+
+func TestReaderJSRendering(t *testing.T) {
+	skipWithoutBrowser(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><noscript>Enable JavaScript</noscript><div id="out"></div>
+<script>document.getElementById("out").textContent = "Rendered"</script></body></html>`)
+	}))
+	defer server.Close()
+
+	reader := New(Options{})
+	defer reader.Close()
+	content, err := reader.ProcessUrl(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "Rendered") {
+		t.Errorf("content %q, want the rendered text", content)
+	}
+}
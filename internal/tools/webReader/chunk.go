@@ -0,0 +1,26 @@
+package webreader
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk returns at most maxChars characters of the text starting at the offset character
+// and the offset of the next chunk, which equals the text length at the end.
+// The chunk is cut at the last line break in its second half to keep the paragraphs whole.
+func Chunk(text string, offset, maxChars int) (string, int) {
+	runes := []rune(text)
+	offset = min(max(offset, 0), len(runes))
+	if maxChars <= 0 || offset+maxChars >= len(runes) {
+		return string(runes[offset:]), len(runes)
+	}
+
+	end := offset + maxChars
+	chunk := string(runes[offset:end])
+	if idx := strings.LastIndexByte(chunk, '\n'); idx != -1 {
+		if cut := utf8.RuneCountInString(chunk[:idx+1]); cut >= maxChars/2 {
+			chunk, end = chunk[:idx+1], offset+cut
+		}
+	}
+	return chunk, end
+}
@@ -0,0 +1,171 @@
+package webreader
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Elements never containing the main content
+const junkSelector = "script, style, noscript, template, iframe, svg, canvas, form, button, input, select, textarea, " +
+	"nav, footer, aside, dialog, " +
+	`[role="navigation"], [role="banner"], [role="contentinfo"], [role="complementary"], [role="dialog"], [role="alertdialog"], ` +
+	`[aria-hidden="true"], [hidden]`
+
+var (
+	unlikelyPattern = regexp.MustCompile(`(?i)cookie|consent|gdpr|banner|footer|sidebar|\bnav|menu|share|social|comment|promo|sponsor|advert|\bads?\b|newsletter|subscribe|popup|modal|breadcrumb|related|pagination|skip`)
+	likelyPattern   = regexp.MustCompile(`(?i)article|content|main|post|entry|story|text|body`)
+	spacePattern    = regexp.MustCompile(`\s+`)
+)
+
+// article is the main content of the HTML document with its metadata.
+type article struct {
+	Title     string
+	Author    string
+	Published string
+	HTML      string
+}
+
+// extractArticle finds the main content of the document, dropping the navigation, footers, banners and scripts.
+// The content container is the single article or main element if present, otherwise the element
+// with the highest score of the paragraphs text, in the manner of the readability algorithm.
+func extractArticle(doc *goquery.Document) article {
+	a := article{
+		Title:     extractTitle(doc),
+		Author:    firstAttr(doc, "content", `meta[name="author"]`, `meta[property="article:author"]`, `meta[name="dc.creator"]`),
+		Published: firstAttr(doc, "content", `meta[property="article:published_time"]`, `meta[name="date"]`, `meta[name="dc.date"]`, `meta[itemprop="datePublished"]`),
+	}
+	if a.Author == "" {
+		a.Author = firstText(doc, `[itemprop="author"]`, `[rel="author"]`, ".author", ".byline")
+	}
+	if a.Published == "" {
+		a.Published = firstAttr(doc, "datetime", "article time[datetime]", "time[datetime]")
+	}
+
+	doc.Find(junkSelector).Remove()
+	doc.Find("header").Each(func(_ int, s *goquery.Selection) {
+		// The article header keeps the title and the byline, the page header is the navigation
+		if s.Closest("article, main").Length() == 0 {
+			s.Remove()
+		}
+	})
+	doc.Find("div, section, ul, ol, table, p, span").Each(func(_ int, s *goquery.Selection) {
+		names := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyPattern.MatchString(names) && !likelyPattern.MatchString(names) {
+			s.Remove()
+		}
+	})
+
+	content := mainContent(doc)
+	contentHTML, err := goquery.OuterHtml(content)
+	if err != nil {
+		contentHTML, _ = doc.Find("body").Html()
+	}
+	a.HTML = contentHTML
+	return a
+}
+
+func mainContent(doc *goquery.Document) *goquery.Selection {
+	for _, selector := range []string{"article", "main", `[role="main"]`, `[itemprop="articleBody"]`} {
+		if s := doc.Find(selector); s.Length() == 1 && textLength(s) > 200 {
+			return s
+		}
+	}
+
+	scores := map[*html.Node]float64{}
+	candidates := map[*html.Node]*goquery.Selection{}
+	// The nodes in the document order, so the ties are resolved to the first candidate
+	var order []*html.Node
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 || goquery.NodeName(s) == "body" || goquery.NodeName(s) == "html" {
+			return
+		}
+		node := s.Get(0)
+		if _, ok := candidates[node]; !ok {
+			candidates[node] = s
+			order = append(order, node)
+			names := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+			if likelyPattern.MatchString(names) {
+				scores[node] += 25
+			}
+		}
+		scores[node] += score
+	}
+
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, s *goquery.Selection) {
+		length := textLength(s)
+		if length < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(s.Text(), ",")) + math.Min(float64(length)/100, 3)
+		addScore(s.Parent(), score)
+		addScore(s.Parent().Parent(), score/2)
+	})
+
+	var best *goquery.Selection
+	var bestScore float64
+	for _, node := range order {
+		// Lists of links, such as the menus, are penalized
+		s := candidates[node]
+		score := scores[node] * (1 - linkDensity(s))
+		if best == nil || score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	if best == nil {
+		return doc.Find("body")
+	}
+	return best
+}
+
+func extractTitle(doc *goquery.Document) string {
+	if title := firstAttr(doc, "content", `meta[property="og:title"]`, `meta[name="twitter:title"]`); title != "" {
+		return title
+	}
+	if title := firstText(doc, "article h1", "h1"); title != "" {
+		return title
+	}
+	return firstText(doc, "title")
+}
+
+func firstAttr(doc *goquery.Document, attr string, selectors ...string) string {
+	for _, selector := range selectors {
+		if value := strings.TrimSpace(doc.Find(selector).First().AttrOr(attr, "")); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func firstText(doc *goquery.Document, selectors ...string) string {
+	for _, selector := range selectors {
+		if text := normalizeSpace(doc.Find(selector).First().Text()); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func textLength(s *goquery.Selection) int {
+	return utf8.RuneCountInString(normalizeSpace(s.Text()))
+}
+
+func linkDensity(s *goquery.Selection) float64 {
+	length := textLength(s)
+	if length == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += textLength(a)
+	})
+	return math.Min(float64(links)/float64(length), 1)
+}
+
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
@@ -1,8 +1,13 @@
 package webreader
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
@@ -10,51 +15,356 @@ import (
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
 	"github.com/PuerkitoBio/goquery"
-	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/proto"
+	"golang.org/x/net/html/charset"
 )
 
-func ProcessUrl(u string) (string, error) {
-	requiresJS, err := checkNoScript(u)
+const (
+	// DefaultTimeout bounds the whole page processing, including the browser rendering.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxBodySize is the maximum size in bytes of the downloaded response body.
+	DefaultMaxBodySize = 5 * 1024 * 1024
+	// DefaultMaxRedirects is the maximum number of redirects followed per request.
+	DefaultMaxRedirects = 10
+	// DefaultUserAgent is sent with the requests and by the headless browser.
+	DefaultUserAgent = "Mozilla/5.0 (compatible; libagent-webreader/1.0)"
+)
+
+// JS rendering modes of the HTML pages.
+const (
+	// JSRenderingAuto renders the page in the browser if it has a noscript notice about JavaScript
+	JSRenderingAuto = "auto"
+	// JSRenderingAlways renders every HTML page in the browser
+	JSRenderingAlways = "always"
+	// JSRenderingNever converts the fetched HTML without rendering
+	JSRenderingNever = "never"
+)
+
+var (
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrBodyTooLarge           = errors.New("response body too large")
+)
+
+// Reader fetches the pages once and converts them to the text readable by the model.
+type Reader struct {
+	Client      *http.Client
+	UserAgent   string
+	MaxBodySize int64
+	// Timeout bounds the whole page processing, zero disables it
+	Timeout time.Duration
+	// JSRendering is one of the JSRendering modes, auto if empty
+	JSRendering string
+	// Browser renders the JS pages, the shared default pool is used if nil
+	Browser *BrowserPool
+}
+
+// Options configures the reader created by New, zero values are replaced with the defaults.
+type Options struct {
+	// Client is used instead of the one built from Timeout and MaxRedirects if set
+	Client       *http.Client
+	Timeout      time.Duration
+	MaxBodySize  int64
+	MaxRedirects int
+	UserAgent    string
+
+	JSRendering        string
+	MaxPages           int
+	BrowserIdleTimeout time.Duration
+}
+
+// Extraction modes of the HTML pages.
+const (
+	// ModeArticle keeps the main content of the page with its title, author and date
+	ModeArticle = "article"
+	// ModeFull converts the whole page
+	ModeFull = "full"
+)
+
+// ReadOptions selects the part of the HTML page to convert.
+type ReadOptions struct {
+	// Mode is one of the extraction modes, full if empty
+	Mode string
+	// Selector is the CSS selector of the converted elements, overriding the mode
+	Selector string
+}
+
+// Document is the page converted to markdown.
+type Document struct {
+	URL       string
+	Title     string
+	Author    string
+	Published string
+	Content   string
+}
+
+// String renders the document metadata before the content.
+func (d Document) String() string {
+	var sb strings.Builder
+	if d.Title != "" {
+		sb.WriteString("Title: " + d.Title + "\n")
+	}
+	if d.Author != "" {
+		sb.WriteString("Author: " + d.Author + "\n")
+	}
+	if d.Published != "" {
+		sb.WriteString("Published: " + d.Published + "\n")
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("Source: " + d.URL + "\n\n")
+	}
+	sb.WriteString(d.Content)
+	return sb.String()
+}
+
+// resource is the fetched response.
+type resource struct {
+	URL       string
+	MediaType string
+	Params    map[string]string
+	Body      []byte
+}
+
+var (
+	defaultBrowser = NewBrowserPool(0, 0)
+	defaultReader  = &Reader{
+		Client:      NewClient(DefaultTimeout, DefaultMaxRedirects),
+		UserAgent:   DefaultUserAgent,
+		MaxBodySize: DefaultMaxBodySize,
+		Timeout:     DefaultTimeout,
+		Browser:     defaultBrowser,
+	}
+)
+
+// New returns the reader with the HTTP client limited by the options timeout and redirects,
+// owning its browser pool which is shut down by Close.
+func New(opts Options) *Reader {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.JSRendering == "" {
+		opts.JSRendering = JSRenderingAuto
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = NewClient(opts.Timeout, opts.MaxRedirects)
+	}
+
+	return &Reader{
+		Client:      client,
+		UserAgent:   opts.UserAgent,
+		MaxBodySize: opts.MaxBodySize,
+		Timeout:     opts.Timeout,
+		JSRendering: opts.JSRendering,
+		Browser:     NewBrowserPool(opts.MaxPages, opts.BrowserIdleTimeout),
+	}
+}
+
+// Close shuts down the reader browser.
+func (r *Reader) Close() error {
+	if r.Browser == nil || r.Browser == defaultBrowser {
+		return nil
+	}
+	return r.Browser.Close()
+}
+
+// NewClient returns the HTTP client with the request timeout, following at most maxRedirects redirects.
+func NewClient(timeout time.Duration, maxRedirects int) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// ProcessUrl reads the URL with the default reader.
+func ProcessUrl(ctx context.Context, u string) (string, error) {
+	return defaultReader.ProcessUrl(ctx, u)
+}
+
+// ProcessUrl fetches the page and converts it to markdown, rendering it in the headless browser if it requires JS.
+// Plain text is returned as is and JSON is indented, other content types are rejected with ErrUnsupportedContentType.
+// The request and the browser are stopped on the context cancellation.
+func (r *Reader) ProcessUrl(ctx context.Context, u string) (string, error) {
+	doc, err := r.Read(ctx, u, ReadOptions{})
 	if err != nil {
 		return "", err
 	}
+	return doc.Content, nil
+}
+
+// Read fetches the page like ProcessUrl, extracting the HTML part selected by the options.
+func (r *Reader) Read(ctx context.Context, u string, opts ReadOptions) (Document, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	p, err := r.fetch(ctx, u)
+	if err != nil {
+		return Document{}, err
+	}
 
-	var body io.Reader
+	doc := Document{URL: p.URL}
+	switch {
+	case p.MediaType == "text/html" || p.MediaType == "application/xhtml+xml":
+		return r.convertHTML(ctx, p, opts)
+	case p.MediaType == "application/json" || strings.HasSuffix(p.MediaType, "+json"):
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, p.Body, "", "  "); err != nil {
+			doc.Content = string(p.Body)
+		} else {
+			doc.Content = indented.String()
+		}
+		return doc, nil
+	case strings.HasPrefix(p.MediaType, "text/") ||
+		p.MediaType == "application/xml" || strings.HasSuffix(p.MediaType, "+xml"):
+		doc.Content, err = decode(p)
+		return doc, err
+	default:
+		return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedContentType, p.MediaType)
+	}
+}
 
+func (r *Reader) convertHTML(ctx context.Context, p resource, opts ReadOptions) (Document, error) {
+	html, err := decode(p)
+	if err != nil {
+		return Document{}, err
+	}
+
+	var requiresJS bool
+	switch r.JSRendering {
+	case JSRenderingAlways:
+		requiresJS = true
+	case JSRenderingNever:
+	default:
+		requiresJS, err = checkNoScript(html)
+		if err != nil {
+			return Document{}, err
+		}
+	}
 	if requiresJS {
-		html, err := loadJSPage(u)
+		html, err = r.loadJSPage(ctx, p.URL)
 		if err != nil {
-			return "", err
+			return Document{}, err
 		}
-		body = strings.NewReader(html)
-	} else {
-		resp, err := http.Get(u)
+	}
+
+	doc := Document{URL: p.URL}
+	if opts.Selector != "" || opts.Mode == ModeArticle {
+		page, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 		if err != nil {
-			return "", err
+			return Document{}, err
+		}
+		if opts.Selector != "" {
+			selection := page.Find(opts.Selector)
+			if selection.Length() == 0 {
+				return Document{}, fmt.Errorf("selector %q matched no elements", opts.Selector)
+			}
+			doc.Title = extractTitle(page)
+			html = ""
+			selection.Each(func(_ int, s *goquery.Selection) {
+				part, _ := goquery.OuterHtml(s)
+				html += part + "\n"
+			})
+		} else {
+			a := extractArticle(page)
+			doc.Title, doc.Author, doc.Published = a.Title, a.Author, a.Published
+			html = a.HTML
 		}
-		defer resp.Body.Close()
-		body = resp.Body
 	}
 
-	md, err := htmltomarkdown.ConvertReader(
-		body,
-		converter.WithDomain(u),
+	doc.Content, err = htmltomarkdown.ConvertString(
+		html,
+		converter.WithDomain(p.URL),
 	)
-	return string(md), err
+	return doc, err
 }
 
-func loadJSPage(u string) (string, error) {
-	browser := rod.New().MustConnect()
-	defer browser.Close()
+// fetch downloads the URL once, failing on the error status or the body exceeding MaxBodySize.
+func (r *Reader) fetch(ctx context.Context, u string) (resource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return resource{}, err
+	}
+	req.Header.Set("User-Agent", r.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json,text/plain;q=0.9,*/*;q=0.5")
 
-	page, err := browser.Page(proto.TargetCreateTarget{
-		URL: u,
-	})
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
 	if err != nil {
+		return resource{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resource{}, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	reader := io.Reader(resp.Body)
+	if r.MaxBodySize > 0 {
+		if resp.ContentLength > r.MaxBodySize {
+			return resource{}, fmt.Errorf("%w: %d bytes, limit %d", ErrBodyTooLarge, resp.ContentLength, r.MaxBodySize)
+		}
+		reader = io.LimitReader(resp.Body, r.MaxBodySize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return resource{}, fmt.Errorf("read body: %w", err)
+	}
+	if r.MaxBodySize > 0 && int64(len(body)) > r.MaxBodySize {
+		return resource{}, fmt.Errorf("%w: limit %d bytes", ErrBodyTooLarge, r.MaxBodySize)
+	}
+
+	p := resource{
+		URL:  resp.Request.URL.String(),
+		Body: body,
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	p.MediaType, p.Params, err = mime.ParseMediaType(contentType)
+	if err != nil {
+		return resource{}, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+	return p, nil
+}
+
+func (r *Reader) loadJSPage(ctx context.Context, u string) (string, error) {
+	browser := r.Browser
+	if browser == nil {
+		browser = defaultBrowser
+	}
+	page, release, err := browser.Page(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: r.UserAgent}); err != nil {
+		return "", err
+	}
+	if err := page.Navigate(u); err != nil {
 		return "", err
 	}
-	defer page.Close()
 
 	err = page.WaitLoad()
 	if err != nil {
@@ -68,18 +378,21 @@ func loadJSPage(u string) (string, error) {
 	return page.HTML()
 }
 
-func checkNoScript(url string) (bool, error) {
-	resp, err := http.Get(url)
+// decode converts the body to UTF-8 using the charset from the content type or the document itself.
+func decode(p resource) (string, error) {
+	reader, err := charset.NewReader(bytes.NewReader(p.Body), mime.FormatMediaType(p.MediaType, p.Params))
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("decode body: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("decode body: %w", err)
 	}
+	return string(text), nil
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+func checkNoScript(html string) (bool, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return false, err
 	}
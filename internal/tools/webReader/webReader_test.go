@@ -0,0 +1,198 @@
+package webreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReaderRead(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     error
+	}{
+		{"html", "text/html; charset=utf-8", "<html><body><h1>Title</h1><p>Text</p></body></html>", "# Title\n\nText", nil},
+		{"json", "application/json", `{"a":[1,2]}`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}", nil},
+		{"invalid json", "application/problem+json", `{"a":`, `{"a":`, nil},
+		{"plain text", "text/plain", "plain text", "plain text", nil},
+		{"latin1 text", "text/plain; charset=iso-8859-1", "caf\xe9", "café", nil},
+		{"xml", "application/rss+xml", "<rss/>", "<rss/>", nil},
+		{"detected html", "", "<html><body><p>Detected</p></body></html>", "Detected", nil},
+		{"image", "image/png", "\x89PNG", "", ErrUnsupportedContentType},
+		{"invalid content type", "text/html; charset", "<p>x</p>", "", ErrUnsupportedContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				} else {
+					// Suppress the content type sniffing of the server
+					w.Header()["Content-Type"] = nil
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			reader := New(Options{JSRendering: JSRenderingNever})
+			defer reader.Close()
+			doc, err := reader.Read(context.Background(), server.URL, ReadOptions{Mode: ModeFull})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.TrimSpace(doc.Content); got != tt.want {
+				t.Errorf("content %q, want %q", got, tt.want)
+			}
+			if n := requests.Load(); n != 1 {
+				t.Errorf("%d requests, want a single fetch", n)
+			}
+		})
+	}
+}
+
+func TestReaderStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := New(Options{}).ProcessUrl(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("error %v, want the status code error", err)
+	}
+}
+
+func TestReaderRedirects(t *testing.T) {
+	// /N redirects to /N-1 until /0 which returns the page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/%d", &n)
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "done")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		redirects int
+		wantErr   bool
+	}{
+		{0, false},
+		{3, false},
+		{4, true},
+	}
+	reader := New(Options{MaxRedirects: 3})
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.redirects), func(t *testing.T) {
+			doc, err := reader.Read(context.Background(), fmt.Sprintf("%s/%d", server.URL, tt.redirects), ReadOptions{})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+					t.Errorf("error %v, want the redirect limit", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if doc.URL != server.URL+"/0" || doc.Content != "done" {
+				t.Errorf("document %s %q, want the final page", doc.URL, doc.Content)
+			}
+		})
+	}
+}
+
+func TestReaderMaxBodySize(t *testing.T) {
+	const limit = 16
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var size int
+		fmt.Sscanf(r.URL.Query().Get("size"), "%d", &size)
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Query().Has("chunked") {
+			// The flush before the body sends it without the content length
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", fmt.Sprint(size))
+		}
+		fmt.Fprint(w, strings.Repeat("x", size))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"at the limit", "size=16", false},
+		{"content length over the limit", "size=17", true},
+		{"chunked at the limit", "size=16&chunked", false},
+		{"chunked over the limit", "size=1000&chunked", true},
+	}
+	reader := New(Options{MaxBodySize: limit})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.Read(context.Background(), server.URL+"/?"+tt.query, ReadOptions{})
+			if tt.wantErr {
+				if !errors.Is(err, ErrBodyTooLarge) {
+					t.Errorf("error %v, want %v", err, ErrBodyTooLarge)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(doc.Content) != limit {
+				t.Errorf("content of %d bytes, want %d", len(doc.Content), limit)
+			}
+		})
+	}
+}
+
+func TestReaderModes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><nav><a href="/">Home</a></nav>
+<article><h1>Story</h1><p>The main content of the story, long enough to be the article.</p></article></body></html>`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		mode    string
+		wantNav bool
+	}{
+		{"", true},
+		{ModeFull, true},
+		{ModeArticle, false},
+	}
+	reader := New(Options{JSRendering: JSRenderingNever})
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			doc, err := reader.Read(context.Background(), server.URL, ReadOptions{Mode: tt.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(doc.Content, "main content") {
+				t.Errorf("content %q, want the article", doc.Content)
+			}
+			if got := strings.Contains(doc.Content, "Home"); got != tt.wantNav {
+				t.Errorf("navigation kept %t, want %t, content %q", got, tt.wantNav, doc.Content)
+			}
+		})
+	}
+}
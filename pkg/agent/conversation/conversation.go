@@ -0,0 +1,107 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Conversation memory kinds.
+const (
+	KindBuffer  = "buffer"
+	KindWindow  = "window"
+	KindSummary = "summary"
+)
+
+// Session store backends.
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendPostgres = "postgres"
+)
+
+const (
+	// DefaultMaxMessages is the number of the messages kept by the buffer memory.
+	DefaultMaxMessages = 50
+	// DefaultMaxTokens is the estimated size of the history kept by the window and the summary memories.
+	DefaultMaxTokens = 4000
+)
+
+// Session is the stored conversation.
+type Session struct {
+	// Summary is the rolling summary of the messages dropped from the history
+	Summary   string                `json:"summary,omitempty"`
+	Messages  []llms.MessageContent `json:"messages"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// Store keeps the sessions by ID.
+type Store interface {
+	// Load returns the empty session if it does not exist
+	Load(ctx context.Context, sessionID string) (Session, error)
+	Save(ctx context.Context, sessionID string, session Session) error
+	// Update loads the session, changes it by the update function and saves it, unless the function fails.
+	// The updates of the same session are serialized, including the ones of the other agents sharing
+	// the file or the database, the different sessions are updated concurrently.
+	Update(ctx context.Context, sessionID string, update func(*Session) error) error
+	Close() error
+}
+
+// Memory keeps the conversation history of the sessions, bounded by its own policy.
+type Memory interface {
+	// Messages returns the history to send before the new messages of the session
+	Messages(ctx context.Context, sessionID string) ([]llms.MessageContent, error)
+	// Append adds the messages of the turn to the session history
+	Append(ctx context.Context, sessionID string, messages ...llms.MessageContent) error
+	Close() error
+}
+
+// NewMemory returns the conversation memory configured by ConversationMemory, nil if it is empty.
+// The summary memory uses the llm to summarize the dropped messages.
+func NewMemory(ctx context.Context, cfg config.Config, llm llms.Model) (Memory, error) {
+	if cfg.ConversationMemory == "" {
+		return nil, nil
+	}
+	store, err := NewStore(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.ConversationMemory {
+	case KindBuffer:
+		return &BufferMemory{Store: store, MaxMessages: cfg.ConversationMaxMessages}, nil
+	case KindWindow:
+		return &WindowMemory{Store: store, MaxTokens: cfg.ConversationMaxTokens}, nil
+	case KindSummary:
+		if llm == nil {
+			store.Close()
+			return nil, fmt.Errorf("summary conversation memory needs the LLM")
+		}
+		return &SummaryMemory{Store: store, LLM: llm, MaxTokens: cfg.ConversationMaxTokens}, nil
+	}
+	store.Close()
+	return nil, fmt.Errorf("unknown conversation memory %q", cfg.ConversationMemory)
+}
+
+// NewStore returns the session store configured by ConversationBackend, in memory by default.
+func NewStore(ctx context.Context, cfg config.Config) (Store, error) {
+	switch cfg.ConversationBackend {
+	case "", BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendFile:
+		if cfg.ConversationDir == "" {
+			return nil, fmt.Errorf("empty conversation dir")
+		}
+		return NewFileStore(cfg.ConversationDir)
+	case BackendPostgres:
+		if cfg.ConversationDBConnection == "" {
+			return nil, fmt.Errorf("empty conversation DB connection string")
+		}
+		return NewPostgresStore(ctx, cfg.ConversationDBConnection)
+	}
+	return nil, fmt.Errorf("unknown conversation backend %q", cfg.ConversationBackend)
+}
@@ -0,0 +1,241 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// BufferMemory keeps the last MaxMessages messages of the session.
+type BufferMemory struct {
+	Store Store
+	// MaxMessages is DefaultMaxMessages if zero
+	MaxMessages int
+}
+
+func (m *BufferMemory) Messages(ctx context.Context, sessionID string) ([]llms.MessageContent, error) {
+	session, err := m.Store.Load(ctx, sessionID)
+	return session.Messages, err
+}
+
+func (m *BufferMemory) Append(ctx context.Context, sessionID string, messages ...llms.MessageContent) error {
+	maxMessages := m.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = DefaultMaxMessages
+	}
+
+	return appendMessages(ctx, m.Store, sessionID, messages, func(session *Session) error {
+		session.Messages = trimStart(session.Messages, len(session.Messages)-maxMessages)
+		return nil
+	})
+}
+
+func (m *BufferMemory) Close() error {
+	return m.Store.Close()
+}
+
+// WindowMemory keeps the last messages of the session fitting into MaxTokens estimated tokens.
+type WindowMemory struct {
+	Store Store
+	// MaxTokens is DefaultMaxTokens if zero
+	MaxTokens int
+}
+
+func (m *WindowMemory) Messages(ctx context.Context, sessionID string) ([]llms.MessageContent, error) {
+	session, err := m.Store.Load(ctx, sessionID)
+	return session.Messages, err
+}
+
+func (m *WindowMemory) Append(ctx context.Context, sessionID string, messages ...llms.MessageContent) error {
+	maxTokens := m.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	return appendMessages(ctx, m.Store, sessionID, messages, func(session *Session) error {
+		session.Messages = trimStart(session.Messages, windowStart(session.Messages, maxTokens))
+		return nil
+	})
+}
+
+func (m *WindowMemory) Close() error {
+	return m.Store.Close()
+}
+
+// SummaryMemory keeps the last messages of the session fitting into the half of MaxTokens,
+// the older ones are folded by the LLM into the rolling summary once the history exceeds MaxTokens.
+// The summarization runs outside of the session update, so the turns of the session do not wait for it.
+// The summary is saved only if the session did not change meanwhile, otherwise it is retried on the next turn.
+type SummaryMemory struct {
+	Store Store
+	LLM   llms.Model
+	// MaxTokens is DefaultMaxTokens if zero
+	MaxTokens   int
+	CallOptions []llms.CallOption
+}
+
+// errSessionChanged cancels the summary update of the session changed during the summarization.
+var errSessionChanged = errors.New("session changed during the summarization")
+
+func (m *SummaryMemory) Messages(ctx context.Context, sessionID string) ([]llms.MessageContent, error) {
+	session, err := m.Store.Load(ctx, sessionID)
+	if err != nil || session.Summary == "" {
+		return session.Messages, err
+	}
+	return append([]llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "Summary of the earlier conversation:\n"+session.Summary),
+	}, session.Messages...), nil
+}
+
+func (m *SummaryMemory) Append(ctx context.Context, sessionID string, messages ...llms.MessageContent) error {
+	maxTokens := m.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	err := appendMessages(ctx, m.Store, sessionID, messages, func(session *Session) error {
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	session, err := m.Store.Load(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if EstimateTokens(session.Summary)+messagesTokens(session.Messages) <= maxTokens {
+		return nil
+	}
+	start := windowStart(session.Messages, maxTokens/2)
+	kept := trimStart(session.Messages, start)
+	dropped := session.Messages[:len(session.Messages)-len(kept)]
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	summary, err := m.summarize(ctx, session.Summary, dropped)
+	if err != nil {
+		// The turn is saved anyway, the summarization is retried on the next one
+		log.Warn().Err(err).Msgf("conversation %s summarization", sessionID)
+		return nil
+	}
+	err = m.Store.Update(ctx, sessionID, func(current *Session) error {
+		if !current.UpdatedAt.Equal(session.UpdatedAt) {
+			return errSessionChanged
+		}
+		current.Summary = summary
+		current.Messages = kept
+		return nil
+	})
+	if errors.Is(err, errSessionChanged) {
+		log.Debug().Msgf("conversation %s changed during the summarization, retried on the next turn", sessionID)
+		return nil
+	}
+	return err
+}
+
+func (m *SummaryMemory) summarize(ctx context.Context, summary string, messages []llms.MessageContent) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Update the summary of the conversation with the new messages below. ")
+	sb.WriteString("Keep the facts, the decisions, the user preferences and the open tasks, drop the small talk. ")
+	sb.WriteString("Answer only with the updated summary.\n\n")
+	if summary != "" {
+		fmt.Fprintf(&sb, "Current summary:\n%s\n\n", summary)
+	}
+	sb.WriteString("New messages:\n")
+	for _, message := range messages {
+		fmt.Fprintf(&sb, "%s: %s\n", message.Role, messageText(message))
+	}
+
+	updated, err := llms.GenerateFromSinglePrompt(ctx, m.LLM, sb.String(), m.CallOptions...)
+	if err != nil {
+		return "", err
+	}
+	updated = strings.TrimSpace(updated)
+	if updated == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return updated, nil
+}
+
+func (m *SummaryMemory) Close() error {
+	return m.Store.Close()
+}
+
+// appendMessages appends the messages to the session and compacts it in one store update,
+// so the concurrent turns of the session are not lost.
+func appendMessages(ctx context.Context, store Store, sessionID string, messages []llms.MessageContent, compact func(*Session) error) error {
+	return store.Update(ctx, sessionID, func(session *Session) error {
+		session.Messages = append(session.Messages, messages...)
+		return compact(session)
+	})
+}
+
+// trimStart drops the messages before start, moving it to the next human message so the history
+// does not begin with the answer. The last message is always kept.
+func trimStart(messages []llms.MessageContent, start int) []llms.MessageContent {
+	if start <= 0 {
+		return messages
+	}
+	start = min(start, len(messages)-1)
+	for i := start; i < len(messages); i++ {
+		if messages[i].Role == llms.ChatMessageTypeHuman {
+			start = i
+			break
+		}
+	}
+	return messages[start:]
+}
+
+// windowStart returns the index of the first message of the longest tail fitting into maxTokens.
+func windowStart(messages []llms.MessageContent, maxTokens int) int {
+	tokens := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		tokens += messageTokens(messages[i])
+		if tokens > maxTokens {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// EstimateTokens approximates the number of the tokens in the text, about four characters each.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+func messagesTokens(messages []llms.MessageContent) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += messageTokens(message)
+	}
+	return tokens
+}
+
+// messageTokens adds the role and the formatting overhead to the message text tokens.
+func messageTokens(message llms.MessageContent) int {
+	return EstimateTokens(messageText(message)) + 4
+}
+
+func messageText(message llms.MessageContent) string {
+	var sb strings.Builder
+	for _, part := range message.Parts {
+		switch part := part.(type) {
+		case llms.TextContent:
+			sb.WriteString(part.Text)
+		case llms.ToolCall:
+			if part.FunctionCall != nil {
+				fmt.Fprintf(&sb, "[tool call %s %s]", part.FunctionCall.Name, part.FunctionCall.Arguments)
+			}
+		case llms.ToolCallResponse:
+			fmt.Fprintf(&sb, "[tool %s result: %s]", part.Name, part.Content)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,165 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestAppendConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		// stores returns the stores of the two agents sharing the sessions
+		stores func(t *testing.T) (Store, Store)
+	}{
+		{"memory", func(t *testing.T) (Store, Store) {
+			store := NewMemoryStore()
+			return store, store
+		}},
+		{"file", func(t *testing.T) (Store, Store) {
+			first, err := NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return first, second
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := tt.stores(t)
+			agents := []Memory{&BufferMemory{Store: first, MaxMessages: 1000}, &BufferMemory{Store: second, MaxMessages: 1000}}
+
+			const turns = 50
+			var wg sync.WaitGroup
+			for i := range turns {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					message := llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprint(i))
+					if err := agents[i%2].Append(context.Background(), "session", message); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			messages, err := agents[0].Messages(context.Background(), "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != turns {
+				t.Errorf("%d messages stored, want %d", len(messages), turns)
+			}
+		})
+	}
+}
+
+// blockingLLM answers the summary prompts once released.
+type blockingLLM struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (l blockingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	l.started <- struct{}{}
+	<-l.release
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "summary"}}}, nil
+}
+
+func (l blockingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, l, prompt, options...)
+}
+
+func TestSummaryMemorySessionLock(t *testing.T) {
+	llm := blockingLLM{started: make(chan struct{}, 1), release: make(chan struct{})}
+	memory := &SummaryMemory{Store: NewMemoryStore(), LLM: llm, MaxTokens: 20}
+	ctx := context.Background()
+	long := llms.TextParts(llms.ChatMessageTypeHuman, strings.Repeat("word ", 20))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- memory.Append(ctx, "summarized", long, long)
+	}()
+	<-llm.started
+
+	// The other session is not blocked by the summarization
+	appended := make(chan error, 1)
+	go func() {
+		appended <- memory.Append(ctx, "other", llms.TextParts(llms.ChatMessageTypeHuman, "hi"))
+	}()
+	select {
+	case err := <-appended:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("append of the other session waits for the summarization")
+	}
+
+	close(llm.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	messages, err := memory.Messages(ctx, "summarized")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) == 0 || !strings.Contains(messageText(messages[0]), "summary") {
+		t.Errorf("messages %v, want the summary first", messages)
+	}
+}
+
+func TestSummaryMemoryConcurrentTurn(t *testing.T) {
+	llm := blockingLLM{started: make(chan struct{}, 1), release: make(chan struct{})}
+	memory := &SummaryMemory{Store: NewMemoryStore(), LLM: llm, MaxTokens: 20}
+	ctx := context.Background()
+	long := llms.TextParts(llms.ChatMessageTypeHuman, strings.Repeat("word ", 20))
+
+	done := make(chan error, 2)
+	go func() {
+		done <- memory.Append(ctx, "session", long, long)
+	}()
+	<-llm.started
+
+	// The turn of the same session is saved while the first summarization runs, then summarized too
+	go func() {
+		done <- memory.Append(ctx, "session", llms.TextParts(llms.ChatMessageTypeHuman, "hi"))
+	}()
+	select {
+	case <-llm.started:
+	case <-time.After(time.Second):
+		t.Fatal("append of the same session waits for the summarization")
+	}
+	messages, err := memory.Messages(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Errorf("%d messages stored during the summarization, want 3", len(messages))
+	}
+
+	close(llm.release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages, err = memory.Messages(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first summary is discarded as the session changed, the second one keeps the last turn
+	if len(messages) != 2 || messages[0].Role != llms.ChatMessageTypeSystem || messageText(messages[1]) != "hi" {
+		t.Errorf("messages %v, want the summary and the last turn", messages)
+	}
+}
@@ -0,0 +1,306 @@
+package conversation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sessionLocks serializes the updates of the same session in the process.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	mu sync.Mutex
+	// users counts the holders and the waiters, the lock is dropped when none are left
+	users int
+}
+
+// lock locks the session, returning the unlock function.
+func (l *sessionLocks) lock(sessionID string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*sessionLock{}
+	}
+	lock, ok := l.locks[sessionID]
+	if !ok {
+		lock = &sessionLock{}
+		l.locks[sessionID] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(l.locks, sessionID)
+		}
+	}
+}
+
+// updateSession runs the update between the load and the save of the store.
+func updateSession(ctx context.Context, store Store, sessionID string, update func(*Session) error) error {
+	session, err := store.Load(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := update(&session); err != nil {
+		return err
+	}
+	return store.Save(ctx, sessionID, session)
+}
+
+// MemoryStore keeps the sessions in the process memory.
+type MemoryStore struct {
+	mu sync.Mutex
+	// The sessions are kept encoded, so the callers never share the messages slices
+	sessions map[string][]byte
+	updates  sessionLocks
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, sessionID string) (Session, error) {
+	s.mu.Lock()
+	data, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+
+	session := Session{}
+	if !ok {
+		return session, nil
+	}
+	err := json.Unmarshal(data, &session)
+	return session, err
+}
+
+func (s *MemoryStore) Save(ctx context.Context, sessionID string, session Session) error {
+	session.UpdatedAt = time.Now()
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = data
+	return nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, sessionID string, update func(*Session) error) error {
+	unlock := s.updates.lock(sessionID)
+	defer unlock()
+	return updateSession(ctx, s, sessionID, update)
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// FileStore keeps every session in its own JSON file named by the session ID hash.
+// The updates are serialized by the lock file of the session, shared with the other processes.
+type FileStore struct {
+	Dir string
+
+	updates sessionLocks
+}
+
+// NewFileStore returns the store in the dir, creating it if missing.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create conversation dir: %w", err)
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (s *FileStore) Load(ctx context.Context, sessionID string) (Session, error) {
+	session := Session{}
+	data, err := os.ReadFile(s.path(sessionID))
+	if errors.Is(err, os.ErrNotExist) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, fmt.Errorf("corrupted session %s: %w", sessionID, err)
+	}
+	return session, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, sessionID string, session Session) error {
+	session.UpdatedAt = time.Now()
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	// Write to the temp file first, so the interrupted save keeps the previous session
+	tmp, err := os.CreateTemp(s.Dir, ".session_")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(sessionID)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Update(ctx context.Context, sessionID string, update func(*Session) error) error {
+	unlock := s.updates.lock(sessionID)
+	defer unlock()
+
+	lockFile, err := os.OpenFile(s.name(sessionID)+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open session lock: %w", err)
+	}
+	// Closing the file releases the lock
+	defer lockFile.Close()
+	if err := lockSessionFile(lockFile); err != nil {
+		return fmt.Errorf("lock session: %w", err)
+	}
+	return updateSession(ctx, s, sessionID, update)
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) path(sessionID string) string {
+	return s.name(sessionID) + ".json"
+}
+
+// name returns the session files path without the extension.
+func (s *FileStore) name(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+// PostgresStore keeps the sessions in the libagent_conversations table.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore connects to the database, creating the sessions table if missing.
+func NewPostgresStore(ctx context.Context, dbConnection string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, dbConnection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS libagent_conversations (
+	session_id TEXT PRIMARY KEY,
+	session JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)`); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("create conversations table: %w", err)
+	}
+	return &PostgresStore{pool: pool}, nil
+}
+
+func (s *PostgresStore) Load(ctx context.Context, sessionID string) (Session, error) {
+	session := Session{}
+	var data []byte
+	err := s.pool.QueryRow(ctx,
+		`SELECT session FROM libagent_conversations WHERE session_id = $1`, sessionID,
+	).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, fmt.Errorf("corrupted session %s: %w", sessionID, err)
+	}
+	return session, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, sessionID string, session Session) error {
+	session.UpdatedAt = time.Now()
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx,
+		`INSERT INTO libagent_conversations (session_id, session, updated_at) VALUES ($1, $2, $3)
+ON CONFLICT (session_id) DO UPDATE SET session = EXCLUDED.session, updated_at = EXCLUDED.updated_at`,
+		sessionID, data, session.UpdatedAt,
+	)
+	return err
+}
+
+// Update locks the session row with SELECT FOR UPDATE for the update, the missing session row is inserted first.
+func (s *PostgresStore) Update(ctx context.Context, sessionID string, update func(*Session) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO libagent_conversations (session_id, session, updated_at) VALUES ($1, '{}', now())
+ON CONFLICT (session_id) DO NOTHING`,
+		sessionID,
+	); err != nil {
+		return err
+	}
+	var data []byte
+	if err := tx.QueryRow(ctx,
+		`SELECT session FROM libagent_conversations WHERE session_id = $1 FOR UPDATE`, sessionID,
+	).Scan(&data); err != nil {
+		return err
+	}
+	session := Session{}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return fmt.Errorf("corrupted session %s: %w", sessionID, err)
+	}
+
+	if err := update(&session); err != nil {
+		return err
+	}
+	session.UpdatedAt = time.Now()
+	data, err = json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE libagent_conversations SET session = $2, updated_at = $3 WHERE session_id = $1`,
+		sessionID, data, session.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) Close() error {
+	s.pool.Close()
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package conversation
+
+import "os"
+
+// lockSessionFile does nothing, the updates are serialized only in the process.
+func lockSessionFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package conversation
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockSessionFile takes the exclusive lock of the file, waiting for the other holders.
+func lockSessionFile(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
@@ -2,16 +2,30 @@ package generic
 
 import (
 	"context"
+	"slices"
 
 	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/agent/conversation"
 
+	"github.com/rs/zerolog/log"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
+// Recaller returns the long-term memories relevant to the input, formatted for the prompt.
+type Recaller interface {
+	RecallContext(ctx context.Context, input string) (string, error)
+}
+
 type Agent struct {
 	LLM           *openai.LLM
 	ToolsExecutor *tools.ToolsExecutor
+	// Memory if set adds the memories relevant to the last human message before each run
+	Memory Recaller
+	// Conversation if set keeps the history of the SessionID session between the runs,
+	// the state passed to Run then holds only the new messages of the turn
+	Conversation conversation.Memory
+	SessionID    string
 
 	toolsList *[]llms.Tool
 }
@@ -29,9 +43,10 @@ func (a *Agent) Run(
 	}
 
 	opts = append(opts, llms.WithTools(*a.toolsList))
+	prompt := a.withMemories(ctx, a.withHistory(ctx, state))
 
 	response, err := a.LLM.GenerateContent(
-		ctx, state, opts...,
+		ctx, prompt, opts...,
 	)
 	if err != nil {
 		return llms.MessageContent{}, err
@@ -44,7 +59,9 @@ func (a *Agent) Run(
 		content = toolContent
 	}
 
-	return llms.TextParts(llms.ChatMessageTypeAI, content), nil
+	result := llms.TextParts(llms.ChatMessageTypeAI, content)
+	a.saveTurn(ctx, state, result)
+	return result, nil
 }
 
 func (a *Agent) SimpleRun(
@@ -60,12 +77,13 @@ func (a *Agent) SimpleRun(
 	}
 
 	opts = append(opts, llms.WithTools(*a.toolsList))
+	state := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman,
+			input,
+		)}
 
 	response, err := a.LLM.GenerateContent(ctx,
-		[]llms.MessageContent{
-			llms.TextParts(llms.ChatMessageTypeHuman,
-				input,
-			)},
+		a.withMemories(ctx, a.withHistory(ctx, state)),
 		opts...,
 	)
 	if err != nil {
@@ -79,5 +97,85 @@ func (a *Agent) SimpleRun(
 		content = toolContent
 	}
 
+	a.saveTurn(ctx, state, llms.TextParts(llms.ChatMessageTypeAI, content))
 	return content, nil
 }
+
+// withHistory inserts the session history after the leading system messages of the state.
+// The load errors are logged, the run goes on without the history.
+func (a *Agent) withHistory(ctx context.Context, state []llms.MessageContent) []llms.MessageContent {
+	if a.Conversation == nil {
+		return state
+	}
+	history, err := a.Conversation.Messages(ctx, a.SessionID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("load conversation %s", a.SessionID)
+		return state
+	}
+	if len(history) == 0 {
+		return state
+	}
+
+	i := leadingSystemMessages(state)
+	withHistory := make([]llms.MessageContent, 0, len(state)+len(history))
+	withHistory = append(withHistory, state[:i]...)
+	withHistory = append(withHistory, history...)
+	return append(withHistory, state[i:]...)
+}
+
+// saveTurn appends the new messages of the state and the response to the session history.
+// The leading system messages are not saved, they are passed again on every run.
+func (a *Agent) saveTurn(ctx context.Context, state []llms.MessageContent, response llms.MessageContent) {
+	if a.Conversation == nil {
+		return
+	}
+	turn := append(slices.Clone(state[leadingSystemMessages(state):]), response)
+	if err := a.Conversation.Append(ctx, a.SessionID, turn...); err != nil {
+		log.Warn().Err(err).Msgf("save conversation %s", a.SessionID)
+	}
+}
+
+func leadingSystemMessages(state []llms.MessageContent) int {
+	i := 0
+	for i < len(state) && state[i].Role == llms.ChatMessageTypeSystem {
+		i++
+	}
+	return i
+}
+
+// withMemories inserts the recalled memories as the system message after the leading system messages.
+// The recall errors are logged, the run goes on without the memories.
+func (a *Agent) withMemories(ctx context.Context, state []llms.MessageContent) []llms.MessageContent {
+	if a.Memory == nil {
+		return state
+	}
+	input := ""
+	for i := len(state) - 1; i >= 0 && input == ""; i-- {
+		if state[i].Role != llms.ChatMessageTypeHuman {
+			continue
+		}
+		for _, part := range state[i].Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				input += text.Text
+			}
+		}
+	}
+	if input == "" {
+		return state
+	}
+
+	memories, err := a.Memory.RecallContext(ctx, input)
+	if err != nil {
+		log.Warn().Err(err).Msg("recall memories")
+		return state
+	}
+	if memories == "" {
+		return state
+	}
+
+	i := leadingSystemMessages(state)
+	withMemories := make([]llms.MessageContent, 0, len(state)+1)
+	withMemories = append(withMemories, state[:i]...)
+	withMemories = append(withMemories, llms.TextParts(llms.ChatMessageTypeSystem, memories))
+	return append(withMemories, state[i:]...)
+}
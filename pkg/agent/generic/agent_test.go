@@ -0,0 +1,324 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/agent/conversation"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// fakeConversation returns the fixed history and records the appended messages.
+type fakeConversation struct {
+	history  []llms.MessageContent
+	err      error
+	appended []llms.MessageContent
+}
+
+func (c *fakeConversation) Messages(ctx context.Context, sessionID string) ([]llms.MessageContent, error) {
+	return c.history, c.err
+}
+
+func (c *fakeConversation) Append(ctx context.Context, sessionID string, messages ...llms.MessageContent) error {
+	c.appended = append(c.appended, messages...)
+	return c.err
+}
+
+func (c *fakeConversation) Close() error {
+	return nil
+}
+
+// fakeRecaller returns the fixed memories and records the recall input.
+type fakeRecaller struct {
+	memories string
+	err      error
+	input    string
+}
+
+func (r *fakeRecaller) RecallContext(ctx context.Context, input string) (string, error) {
+	r.input = input
+	return r.memories, r.err
+}
+
+func system(text string) llms.MessageContent {
+	return llms.TextParts(llms.ChatMessageTypeSystem, text)
+}
+
+func human(text string) llms.MessageContent {
+	return llms.TextParts(llms.ChatMessageTypeHuman, text)
+}
+
+func ai(text string) llms.MessageContent {
+	return llms.TextParts(llms.ChatMessageTypeAI, text)
+}
+
+// render lists the messages as role: text for the comparison.
+func render(messages []llms.MessageContent) string {
+	lines := make([]string, len(messages))
+	for i, message := range messages {
+		var sb strings.Builder
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				sb.WriteString(text.Text)
+			}
+		}
+		lines[i] = fmt.Sprintf("%s: %s", message.Role, sb.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestWithHistory(t *testing.T) {
+	history := []llms.MessageContent{human("earlier"), ai("earlier answer")}
+	tests := []struct {
+		name         string
+		conversation *fakeConversation
+		state        []llms.MessageContent
+		want         []llms.MessageContent
+	}{
+		{
+			name:  "no conversation",
+			state: []llms.MessageContent{system("rules"), human("question")},
+			want:  []llms.MessageContent{system("rules"), human("question")},
+		},
+		{
+			name:         "after the leading system messages",
+			conversation: &fakeConversation{history: history},
+			state:        []llms.MessageContent{system("rules"), system("tools"), human("question")},
+			want:         []llms.MessageContent{system("rules"), system("tools"), human("earlier"), ai("earlier answer"), human("question")},
+		},
+		{
+			name:         "no system messages",
+			conversation: &fakeConversation{history: history},
+			state:        []llms.MessageContent{human("question")},
+			want:         []llms.MessageContent{human("earlier"), ai("earlier answer"), human("question")},
+		},
+		{
+			name:         "only the leading system messages",
+			conversation: &fakeConversation{history: history},
+			state:        []llms.MessageContent{system("rules"), human("question"), system("reminder")},
+			want:         []llms.MessageContent{system("rules"), human("earlier"), ai("earlier answer"), human("question"), system("reminder")},
+		},
+		{
+			name:         "empty history",
+			conversation: &fakeConversation{},
+			state:        []llms.MessageContent{system("rules"), human("question")},
+			want:         []llms.MessageContent{system("rules"), human("question")},
+		},
+		{
+			name:         "load error",
+			conversation: &fakeConversation{history: history, err: errors.New("unavailable")},
+			state:        []llms.MessageContent{system("rules"), human("question")},
+			want:         []llms.MessageContent{system("rules"), human("question")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &Agent{SessionID: "session"}
+			if tt.conversation != nil {
+				agent.Conversation = tt.conversation
+			}
+			state := render(tt.state)
+			if got, want := render(agent.withHistory(context.Background(), tt.state)), render(tt.want); got != want {
+				t.Errorf("prompt\n%s\nwant\n%s", got, want)
+			}
+			if render(tt.state) != state {
+				t.Errorf("state changed to\n%s", render(tt.state))
+			}
+		})
+	}
+}
+
+func TestSaveTurn(t *testing.T) {
+	tests := []struct {
+		name  string
+		state []llms.MessageContent
+		want  []llms.MessageContent
+	}{
+		{
+			name:  "without the leading system messages",
+			state: []llms.MessageContent{system("rules"), system("tools"), human("question")},
+			want:  []llms.MessageContent{human("question"), ai("answer")},
+		},
+		{
+			name:  "later system messages",
+			state: []llms.MessageContent{system("rules"), human("question"), system("reminder")},
+			want:  []llms.MessageContent{human("question"), system("reminder"), ai("answer")},
+		},
+		{
+			name:  "no system messages",
+			state: []llms.MessageContent{human("question"), ai("tool result"), human("next")},
+			want:  []llms.MessageContent{human("question"), ai("tool result"), human("next"), ai("answer")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := &fakeConversation{}
+			agent := &Agent{Conversation: conversation, SessionID: "session"}
+			// The spare capacity must not be overwritten by the response
+			state := append(make([]llms.MessageContent, 0, len(tt.state)+1), tt.state...)
+			spare := state[:len(state)+1]
+			spare[len(state)] = human("spare")
+
+			agent.saveTurn(context.Background(), state, ai("answer"))
+			if got, want := render(conversation.appended), render(tt.want); got != want {
+				t.Errorf("saved\n%s\nwant\n%s", got, want)
+			}
+			if render(spare[len(state):]) != render([]llms.MessageContent{human("spare")}) {
+				t.Errorf("state backing array changed")
+			}
+		})
+	}
+
+	// The append errors are only logged
+	agent := &Agent{Conversation: &fakeConversation{err: errors.New("unavailable")}, SessionID: "session"}
+	agent.saveTurn(context.Background(), []llms.MessageContent{human("question")}, ai("answer"))
+	(&Agent{}).saveTurn(context.Background(), []llms.MessageContent{human("question")}, ai("answer"))
+}
+
+func TestWithMemories(t *testing.T) {
+	tests := []struct {
+		name      string
+		recaller  *fakeRecaller
+		state     []llms.MessageContent
+		want      []llms.MessageContent
+		wantInput string
+	}{
+		{
+			name:  "no memory",
+			state: []llms.MessageContent{system("rules"), human("question")},
+			want:  []llms.MessageContent{system("rules"), human("question")},
+		},
+		{
+			name:      "after the leading system messages",
+			recaller:  &fakeRecaller{memories: "- the user prefers tea"},
+			state:     []llms.MessageContent{system("rules"), system("tools"), human("earlier"), ai("answer"), human("question")},
+			want:      []llms.MessageContent{system("rules"), system("tools"), system("- the user prefers tea"), human("earlier"), ai("answer"), human("question")},
+			wantInput: "question",
+		},
+		{
+			name:      "no system messages",
+			recaller:  &fakeRecaller{memories: "- the user prefers tea"},
+			state:     []llms.MessageContent{human("question"), ai("tool result")},
+			want:      []llms.MessageContent{system("- the user prefers tea"), human("question"), ai("tool result")},
+			wantInput: "question",
+		},
+		{
+			name:     "no human message",
+			recaller: &fakeRecaller{memories: "- the user prefers tea"},
+			state:    []llms.MessageContent{system("rules")},
+			want:     []llms.MessageContent{system("rules")},
+		},
+		{
+			name:      "nothing recalled",
+			recaller:  &fakeRecaller{},
+			state:     []llms.MessageContent{system("rules"), human("question")},
+			want:      []llms.MessageContent{system("rules"), human("question")},
+			wantInput: "question",
+		},
+		{
+			name:      "recall error",
+			recaller:  &fakeRecaller{memories: "- the user prefers tea", err: errors.New("unavailable")},
+			state:     []llms.MessageContent{system("rules"), human("question")},
+			want:      []llms.MessageContent{system("rules"), human("question")},
+			wantInput: "question",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &Agent{}
+			if tt.recaller != nil {
+				agent.Memory = tt.recaller
+			}
+			if got, want := render(agent.withMemories(context.Background(), tt.state)), render(tt.want); got != want {
+				t.Errorf("prompt\n%s\nwant\n%s", got, want)
+			}
+			if tt.recaller != nil && tt.recaller.input != tt.wantInput {
+				t.Errorf("recall input %q, want %q", tt.recaller.input, tt.wantInput)
+			}
+		})
+	}
+}
+
+func TestRunConversation(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Messages []struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		lines := []string{}
+		for _, message := range request.Messages {
+			var text string
+			if err := json.Unmarshal(message.Content, &text); err != nil {
+				parts := []struct {
+					Text string `json:"text"`
+				}{}
+				json.Unmarshal(message.Content, &parts)
+				for _, part := range parts {
+					text += part.Text
+				}
+			}
+			lines = append(lines, message.Role+": "+text)
+		}
+		prompts = append(prompts, strings.Join(lines, "\n"))
+
+		answer, _ := json.Marshal(fmt.Sprintf("answer %d", len(prompts)))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "1", "object": "chat.completion", "model": "test",
+"choices": [{"index": 0, "message": {"role": "assistant", "content": %s}, "finish_reason": "stop"}]}`, answer)
+	}))
+	defer server.Close()
+
+	llm, err := openai.New(openai.WithBaseURL(server.URL), openai.WithToken("token"), openai.WithModel("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := &conversation.BufferMemory{Store: conversation.NewMemoryStore()}
+	agent := &Agent{
+		LLM:           llm,
+		ToolsExecutor: &tools.ToolsExecutor{},
+		Memory:        &fakeRecaller{memories: "- the user prefers tea"},
+		Conversation:  memory,
+		SessionID:     "session",
+	}
+
+	ctx := context.Background()
+	for _, input := range []string{"first", "second"} {
+		if _, err := agent.Run(ctx, []llms.MessageContent{system("rules"), human(input)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The system messages are sent on every run, the memories are not saved in the history
+	want := strings.Join([]string{
+		"system: rules",
+		"system: - the user prefers tea",
+		"user: first",
+		"assistant: answer 1",
+		"user: second",
+	}, "\n")
+	if len(prompts) != 2 || prompts[1] != want {
+		t.Errorf("prompts %q, want the second one\n%s", prompts, want)
+	}
+	history, err := memory.Messages(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := render(history), render([]llms.MessageContent{human("first"), ai("answer 1"), human("second"), ai("answer 2")}); got != want {
+		t.Errorf("history\n%s\nwant\n%s", got, want)
+	}
+}
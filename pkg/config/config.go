@@ -20,6 +20,16 @@ type Config struct {
 	Model              string             `env:"MODEL"`
 	DefaultCallOptions DefaultCallOptions `env:"AI_DEFAULT_CALL_OPTION"`
 
+	ToolOutputLimit     int `env:"TOOL_OUTPUT_LIMIT"`
+	ToolOutputStoreSize int `env:"TOOL_OUTPUT_STORE_SIZE"`
+
+	// One of memory, disk, postgres, the cache is disabled if empty
+	ToolCacheBackend      string `env:"TOOL_CACHE_BACKEND"`
+	ToolCacheTTL          int    `env:"TOOL_CACHE_TTL"`
+	ToolCacheSize         int    `env:"TOOL_CACHE_SIZE"`
+	ToolCacheDir          string `env:"TOOL_CACHE_DIR"`
+	ToolCacheDBConnection string `env:"TOOL_CACHE_DB_CONNECTION"`
+
 	ReWOODisable            bool               `env:"REWOO_DISABLE"`
 	RewOODefaultCallOptions DefaultCallOptions `env:"REWOO_DEFAULT_CALL_OPTION"`
 
@@ -29,19 +39,99 @@ type Config struct {
 	SemanticSearchDBConnection   string `env:"SEMANTIC_SEARCH_DB_CONNECTION"`
 	SemanticSearchEmbeddingModel string `env:"SEMANTIC_SEARCH_EMBEDDING_MODEL"`
 	SemanticSearchMaxResults     int    `env:"SEMANTIC_SEARCH_MAX_RESULTS"`
+	// The only collections semanticSearch may query, all if empty
+	SemanticSearchCollections []string `env:"SEMANTIC_SEARCH_COLLECTIONS"`
+	// One of postgres, memory
+	SemanticSearchBackend string `env:"SEMANTIC_SEARCH_BACKEND"`
+	// File of the memory backend, .json or gob, the store is not persisted if empty
+	SemanticSearchStorePath string `env:"SEMANTIC_SEARCH_STORE_PATH"`
+	// One of vector, hybrid
+	SemanticSearchMode string `env:"SEMANTIC_SEARCH_MODE"`
+	// One of llm, api, the reranking is disabled if empty
+	SemanticSearchReranker    string `env:"SEMANTIC_SEARCH_RERANKER"`
+	SemanticSearchRerankURL   string `env:"SEMANTIC_SEARCH_RERANK_URL"`
+	SemanticSearchRerankToken string `env:"SEMANTIC_SEARCH_RERANK_TOKEN"`
+	SemanticSearchRerankModel string `env:"SEMANTIC_SEARCH_RERANK_MODEL"`
+	SemanticSearchRerankTopK  int    `env:"SEMANTIC_SEARCH_RERANK_TOP_K"`
+
+	SemanticIndexDisable     bool `env:"SEMANTIC_INDEX_DISABLE"`
+	SemanticIndexChunkSize   int  `env:"SEMANTIC_INDEX_CHUNK_SIZE"`
+	SemanticIndexMaxFileSize int  `env:"SEMANTIC_INDEX_MAX_FILE_SIZE"`
+	// The only directory indexRepository may index by path, the file tools workspace if empty
+	SemanticIndexRoot string `env:"SEMANTIC_INDEX_ROOT"`
+
+	MemoryDisable    bool    `env:"MEMORY_DISABLE"`
+	MemoryNamespace  string  `env:"MEMORY_NAMESPACE"`
+	MemoryMaxResults int     `env:"MEMORY_MAX_RESULTS"`
+	MemoryMinScore   float64 `env:"MEMORY_MIN_SCORE"`
+
+	// Conversation memory: buffer, window or summary, disabled if empty
+	ConversationMemory string `env:"CONVERSATION_MEMORY"`
+	// Session store: memory, file or postgres
+	ConversationBackend      string `env:"CONVERSATION_BACKEND"`
+	ConversationDir          string `env:"CONVERSATION_DIR"`
+	ConversationDBConnection string `env:"CONVERSATION_DB_CONNECTION"`
+	ConversationMaxMessages  int    `env:"CONVERSATION_MAX_MESSAGES"`
+	ConversationMaxTokens    int    `env:"CONVERSATION_MAX_TOKENS"`
 
 	DDGSearchDisable    bool   `env:"DDG_SEARCH_DISABLE"`
 	DDGSearchUserAgent  string `env:"DDG_SEARCH_USER_AGENT"`
 	DDGSearchMaxResults int    `env:"DDG_SEARCH_MAX_RESULTS"`
 
-	WebReaderDisable bool `env:"WEB_READER_DISABLE"`
+	// Search providers tried in order: duckduckgo, searxng, brave, bing, google
+	WebSearchProviders      []string `env:"WEB_SEARCH_PROVIDERS"`
+	WebSearchSearxNGURL     string   `env:"WEB_SEARCH_SEARXNG_URL"`
+	WebSearchBraveAPIKey    string   `env:"WEB_SEARCH_BRAVE_API_KEY"`
+	WebSearchBingAPIKey     string   `env:"WEB_SEARCH_BING_API_KEY"`
+	WebSearchBingURL        string   `env:"WEB_SEARCH_BING_URL"`
+	WebSearchGoogleAPIKey   string   `env:"WEB_SEARCH_GOOGLE_API_KEY"`
+	WebSearchGoogleEngineID string   `env:"WEB_SEARCH_GOOGLE_ENGINE_ID"`
+
+	WebReaderDisable      bool   `env:"WEB_READER_DISABLE"`
+	WebReaderTimeout      int    `env:"WEB_READER_TIMEOUT"`
+	WebReaderMaxBodySize  int    `env:"WEB_READER_MAX_BODY_SIZE"`
+	WebReaderMaxRedirects int    `env:"WEB_READER_MAX_REDIRECTS"`
+	WebReaderUserAgent    string `env:"WEB_READER_USER_AGENT"`
+	// One of full (default), article
+	WebReaderMode string `env:"WEB_READER_MODE"`
+	// One of auto, always, never
+	WebReaderJSRendering        string `env:"WEB_READER_JS_RENDERING"`
+	WebReaderMaxPages           int    `env:"WEB_READER_MAX_PAGES"`
+	WebReaderBrowserIdleTimeout int    `env:"WEB_READER_BROWSER_IDLE_TIMEOUT"`
+
+	WebResearchDisable    bool `env:"WEB_RESEARCH_DISABLE"`
+	WebResearchMaxResults int  `env:"WEB_RESEARCH_MAX_RESULTS"`
+	WebResearchMaxChars   int  `env:"WEB_RESEARCH_MAX_CHARS"`
+	WebResearchTimeout    int  `env:"WEB_RESEARCH_TIMEOUT"`
 
 	NmapDisable    bool `env:"NMAP_DISABLE"`
 	MsfDisable     bool `env:"MSF_DISABLE"`
 	ExploitDisable bool `env:"EXP_DISABLE"`
 
-	CommandExecutorDisable  bool              `env:"COMMAND_EXECUTOR_DISABLE"`
-	CommandExecutorCommands map[string]string `env:"COMMAND_EXECUTOR_CMD_*"`
+	CommandExecutorDisable            bool              `env:"COMMAND_EXECUTOR_DISABLE"`
+	CommandExecutorCommands           map[string]string `env:"COMMAND_EXECUTOR_CMD_*"`
+	CommandExecutorOutputLimit        int               `env:"COMMAND_EXECUTOR_OUTPUT_LIMIT"`
+	CommandExecutorStreamLimit        int               `env:"COMMAND_EXECUTOR_STREAM_LIMIT"`
+	CommandExecutorTimeout            int               `env:"COMMAND_EXECUTOR_TIMEOUT"`
+	CommandExecutorSessionIdleTimeout int               `env:"COMMAND_EXECUTOR_SESSION_IDLE_TIMEOUT"`
+
+	CommandExecutorSandbox             bool `env:"COMMAND_EXECUTOR_SANDBOX"`
+	CommandExecutorSandboxNetwork      bool `env:"COMMAND_EXECUTOR_SANDBOX_NETWORK"`
+	CommandExecutorSandboxCPUSeconds   int  `env:"COMMAND_EXECUTOR_SANDBOX_CPU_SECONDS"`
+	CommandExecutorSandboxMemoryMB     int  `env:"COMMAND_EXECUTOR_SANDBOX_MEMORY_MB"`
+	CommandExecutorSandboxMaxProcesses int  `env:"COMMAND_EXECUTOR_SANDBOX_MAX_PROCESSES"`
+	CommandExecutorSandboxSeccomp      bool `env:"COMMAND_EXECUTOR_SANDBOX_SECCOMP"`
+
+	CommandExecutorWorkspaceDir           string   `env:"COMMAND_EXECUTOR_WORKSPACE_DIR"`
+	CommandExecutorWorkspaceKeep          bool     `env:"COMMAND_EXECUTOR_WORKSPACE_KEEP"`
+	CommandExecutorWorkspaceSeed          []string `env:"COMMAND_EXECUTOR_WORKSPACE_SEED"`
+	CommandExecutorWorkspaceGitRepository string   `env:"COMMAND_EXECUTOR_WORKSPACE_GIT_REPOSITORY"`
+	CommandExecutorWorkspaceGitRef        string   `env:"COMMAND_EXECUTOR_WORKSPACE_GIT_REF"`
+
+	CommandExecutorTranscriptDir string `env:"COMMAND_EXECUTOR_TRANSCRIPT_DIR"`
+
+	FileToolsDisable bool   `env:"FILE_TOOLS_DISABLE"`
+	FileToolsRoot    string `env:"FILE_TOOLS_ROOT"`
 }
 
 // See tmc/langchaingo/llms/options.go
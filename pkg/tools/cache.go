@@ -0,0 +1,432 @@
+package tools
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Response cache backends.
+const (
+	CacheBackendMemory   = "memory"
+	CacheBackendDisk     = "disk"
+	CacheBackendPostgres = "postgres"
+)
+
+// DefaultCacheTTL is the time the cached tool responses are valid for.
+const DefaultCacheTTL = time.Hour
+
+// DefaultCacheSize is the maximum number of entries of the in-memory cache.
+const DefaultCacheSize = 256
+
+// cachePurgeInterval is how often the persistent caches remove the expired entries, checked on Set.
+const cachePurgeInterval = 10 * time.Minute
+
+// ResponseCache keeps the tool responses, such as the fetched pages and the search results, by key.
+type ResponseCache interface {
+	// Get returns the value if it is present and not expired.
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set stores the value for the ttl duration.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Close() error
+}
+
+// NewResponseCache returns the cache of the configured backend, nil if it is not set.
+func NewResponseCache(ctx context.Context, cfg config.Config) (ResponseCache, error) {
+	switch cfg.ToolCacheBackend {
+	case "":
+		return nil, nil
+	case CacheBackendMemory:
+		return NewMemoryCache(cfg.ToolCacheSize), nil
+	case CacheBackendDisk:
+		dir := cfg.ToolCacheDir
+		if dir == "" {
+			userCache, err := os.UserCacheDir()
+			if err != nil {
+				return nil, fmt.Errorf("tool cache dir: %w", err)
+			}
+			dir = filepath.Join(userCache, "libagent", "tools")
+		}
+		return NewDiskCache(dir)
+	case CacheBackendPostgres:
+		if cfg.ToolCacheDBConnection == "" {
+			return nil, fmt.Errorf("tool cache empty DB connection string")
+		}
+		return NewPostgresCache(ctx, cfg.ToolCacheDBConnection)
+	default:
+		return nil, fmt.Errorf("unknown tool cache backend %q", cfg.ToolCacheBackend)
+	}
+}
+
+// sharedResponseCache returns the cache shared by the tools of the executor, nil if it is not set.
+func sharedResponseCache(ctx context.Context, cfg config.Config) (ResponseCache, error) {
+	return sharedResource(ctx, "responseCache", func() (ResponseCache, func() error, error) {
+		cache, err := NewResponseCache(ctx, cfg)
+		if err != nil || cache == nil {
+			return nil, nil, err
+		}
+		return cache, cache.Close, nil
+	})
+}
+
+// cachedCall returns the cached value of the key or stores the fetch result,
+// the cache failures are logged and do not fail the call.
+func cachedCall(ctx context.Context, cache ResponseCache, ttl time.Duration, key string, fetch func() (string, error)) (string, error) {
+	if cache == nil {
+		return fetch()
+	}
+
+	value, ok, err := cache.Get(ctx, key)
+	if err != nil {
+		log.Warn().Err(err).Msgf("tool cache get %s", key)
+	}
+	if ok {
+		log.Debug().Msgf("tool cache hit: %s", key)
+		return value, nil
+	}
+	log.Debug().Msgf("tool cache miss: %s", key)
+
+	value, err = fetch()
+	if err != nil {
+		return value, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if err := cache.Set(ctx, key, value, ttl); err != nil {
+		log.Warn().Err(err).Msgf("tool cache set %s", key)
+	}
+	return value, nil
+}
+
+// NormalizeURL returns the URL cache key form: lowercase scheme and host without the default port,
+// sorted query parameters and no fragment. Unparsable URLs are returned trimmed.
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	// Encode sorts the parameters by key
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+// NormalizeQuery returns the search query cache key form: lowercase with collapsed whitespace.
+func NormalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// MemoryCache is the in-process LRU cache.
+type MemoryCache struct {
+	Size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// NewMemoryCache returns the LRU cache keeping at most size entries, DefaultCacheSize if zero.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &MemoryCache{
+		Size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return "", false, nil
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// DiskCache keeps every entry in its own file named by the key hash, surviving the restarts.
+// The file modification time is set to the entry expiration, so the expired files are removed without reading them.
+type DiskCache struct {
+	Dir string
+
+	purge purgeSchedule
+}
+
+type diskEntry struct {
+	Key     string    `json:"key"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewDiskCache returns the cache in the dir, creating it if missing.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (c *DiskCache) Get(ctx context.Context, key string) (string, bool, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	entry := diskEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		os.Remove(path)
+		return "", false, fmt.Errorf("corrupted cache entry %s: %w", path, err)
+	}
+	if entry.Key != key {
+		return "", false, nil
+	}
+	if time.Now().After(entry.Expires) {
+		os.Remove(path)
+		return "", false, nil
+	}
+	return entry.Value, true, nil
+}
+
+func (c *DiskCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+	data, err := json.Marshal(diskEntry{
+		Key:     key,
+		Value:   value,
+		Expires: expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to the temp file first, so the concurrent readers never see the partial entry
+	tmp, err := os.CreateTemp(c.Dir, ".entry_")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), time.Time{}, expires); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if c.purge.due() {
+		if err := c.removeExpired(); err != nil {
+			log.Warn().Err(err).Msg("tool cache remove expired files")
+		}
+	}
+	return nil
+}
+
+// removeExpired removes the expired entries and the temp files left by the interrupted writes.
+func (c *DiskCache) removeExpired() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	errs := []error{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry.Name(), ".entry_"):
+			// The temp file modification time is the write time until it is renamed
+			if now.Sub(info.ModTime()) < cachePurgeInterval {
+				continue
+			}
+		case strings.HasSuffix(entry.Name(), ".json"):
+			if info.ModTime().After(now) {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *DiskCache) Close() error {
+	return nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.Dir, cacheKeyHash(key)+".json")
+}
+
+// PostgresCache keeps the entries in the libagent_tool_cache table, shared between the agents.
+type PostgresCache struct {
+	pool  *pgxpool.Pool
+	purge purgeSchedule
+}
+
+// NewPostgresCache connects to the database, creating the cache table if missing.
+func NewPostgresCache(ctx context.Context, dbConnection string) (*PostgresCache, error) {
+	pool, err := pgxpool.New(ctx, dbConnection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS libagent_tool_cache (
+	key_hash TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("create cache table: %w", err)
+	}
+	return &PostgresCache{pool: pool}, nil
+}
+
+func (c *PostgresCache) Get(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := c.pool.QueryRow(ctx,
+		`SELECT value FROM libagent_tool_cache WHERE key_hash = $1 AND expires_at > now()`,
+		cacheKeyHash(key),
+	).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (c *PostgresCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.pool.Exec(ctx,
+		`INSERT INTO libagent_tool_cache (key_hash, value, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key_hash) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		cacheKeyHash(key), value, time.Now().Add(ttl),
+	)
+	if err != nil {
+		return err
+	}
+
+	if c.purge.due() {
+		if _, err := c.pool.Exec(ctx, `DELETE FROM libagent_tool_cache WHERE expires_at < now()`); err != nil {
+			log.Warn().Err(err).Msg("tool cache remove expired rows")
+		}
+	}
+	return nil
+}
+
+func (c *PostgresCache) Close() error {
+	c.pool.Close()
+	return nil
+}
+
+// purgeSchedule spaces out the removals of the expired cache entries, the first one is due at once.
+type purgeSchedule struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// due reports whether the purge interval passed since the last removal, starting the next interval if so.
+func (p *purgeSchedule) due() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.last.IsZero() && time.Since(p.last) < cachePurgeInterval {
+		return false
+	}
+	p.last = time.Now()
+	return true
+}
+
+func cacheKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
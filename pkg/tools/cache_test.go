@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	get := func(key string) (string, bool) {
+		t.Helper()
+		value, ok, err := cache.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value, ok
+	}
+
+	cache.Set(ctx, "a", "1", time.Hour)
+	cache.Set(ctx, "b", "2", time.Hour)
+	// The read makes a the most recently used, so b is evicted
+	get("a")
+	cache.Set(ctx, "c", "3", time.Hour)
+	if _, ok := get("b"); ok {
+		t.Errorf("least recently used entry is kept")
+	}
+	for key, want := range map[string]string{"a": "1", "c": "3"} {
+		if value, ok := get(key); !ok || value != want {
+			t.Errorf("entry %s %q, want %q", key, value, want)
+		}
+	}
+
+	// The update of the entry does not evict the others
+	cache.Set(ctx, "a", "4", time.Hour)
+	if value, _ := get("a"); value != "4" || cache.order.Len() != 2 {
+		t.Errorf("entry a %q, %d entries, want the updated value in 2 entries", value, cache.order.Len())
+	}
+	if _, ok := get("c"); !ok {
+		t.Errorf("entry c is evicted by the update")
+	}
+
+	cache.Set(ctx, "expired", "5", -time.Second)
+	if _, ok := get("expired"); ok {
+		t.Errorf("expired entry is returned")
+	}
+	if _, ok := cache.entries["expired"]; ok {
+		t.Errorf("expired entry is kept after the read")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Set(ctx, "key", "value", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := cache.Get(ctx, "key"); err != nil || !ok || value != "value" {
+		t.Errorf("value %q, found %t, error %v, want the stored value", value, ok, err)
+	}
+	// The reopened cache keeps the entries
+	reopened, err := NewDiskCache(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := reopened.Get(ctx, "key"); !ok || value != "value" {
+		t.Errorf("value %q, found %t after reopening, want the stored value", value, ok)
+	}
+	if _, ok, _ := cache.Get(ctx, "missing"); ok {
+		t.Errorf("missing entry is found")
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		if err := cache.Set(ctx, "expired", "value", -time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := cache.Get(ctx, "expired"); ok || err != nil {
+			t.Errorf("found %t, error %v, want the expired entry missing", ok, err)
+		}
+		if _, err := os.Stat(cache.path("expired")); !os.IsNotExist(err) {
+			t.Errorf("expired entry file is kept: %v", err)
+		}
+	})
+
+	t.Run("hash collision", func(t *testing.T) {
+		data, err := os.ReadFile(cache.path("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cache.path("other"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := cache.Get(ctx, "other"); ok || err != nil {
+			t.Errorf("found %t, error %v, want the entry of the other key missing", ok, err)
+		}
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		if err := os.WriteFile(cache.path("corrupted"), []byte(`{"key":`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := cache.Get(ctx, "corrupted"); ok || err == nil {
+			t.Errorf("found %t, error %v, want the corrupted entry error", ok, err)
+		}
+		if _, err := os.Stat(cache.path("corrupted")); !os.IsNotExist(err) {
+			t.Errorf("corrupted entry file is kept: %v", err)
+		}
+	})
+}
+
+func TestDiskCacheAtomicWrite(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The readers racing with the writers see either of the complete values
+	values := []string{strings.Repeat("a", 1<<20), strings.Repeat("b", 1<<20)}
+	errs := make(chan error, 100)
+	wg := sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := cache.Set(ctx, "key", values[i%2], time.Hour); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			value, ok, err := cache.Get(ctx, "key")
+			if err != nil {
+				errs <- err
+			} else if ok && value != values[0] && value != values[1] {
+				errs <- fmt.Errorf("partial value of %d bytes", len(value))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	entries, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(cache.path("key")) {
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("files %v, want only the entry file", names)
+	}
+}
+
+func TestDiskCacheRemoveExpired(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, ttl := range map[string]time.Duration{"valid": time.Hour, "expired": -time.Second, "unread": -time.Hour} {
+		if err := cache.Set(ctx, key, "value", ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The temp files of the interrupted writes are removed once they are old
+	for name, age := range map[string]time.Duration{".entry_old": 2 * cachePurgeInterval, ".entry_new": 0} {
+		path := filepath.Join(cache.Dir, name)
+		if err := os.WriteFile(path, []byte("partial"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first Set purged before the other entries were written, the next one is not due yet
+	if cache.purge.due() {
+		t.Errorf("purge is due right after the first one")
+	}
+	if err := cache.removeExpired(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{".entry_new", filepath.Base(cache.path("valid"))}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("files %v, want %v", names, want)
+	}
+}
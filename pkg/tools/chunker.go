@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"strings"
+)
+
+const (
+	// DefaultChunkMaxChars is the maximum size in bytes of the indexed chunk.
+	DefaultChunkMaxChars = 1500
+	// DefaultChunkOverlapLines is the number of lines repeated at the start of the next chunk.
+	DefaultChunkOverlapLines = 3
+)
+
+// Chunk is the part of the file embedded as a single document, lines are numbered from 1.
+type Chunk struct {
+	Content   string
+	StartLine int
+	EndLine   int
+	// Metadata is stored with the chunk in addition to the file path and lines
+	Metadata map[string]any
+}
+
+// Chunker splits the file content into the chunks for the indexing.
+type Chunker interface {
+	Chunk(path string, content []byte) []Chunk
+}
+
+// LineChunker splits the files by lines, filling the chunks up to MaxChars bytes,
+// the overlapping lines keep the context of the chunk boundaries.
+type LineChunker struct {
+	MaxChars     int
+	OverlapLines int
+}
+
+func (c LineChunker) Chunk(path string, content []byte) []Chunk {
+	maxChars := c.MaxChars
+	if maxChars <= 0 {
+		maxChars = DefaultChunkMaxChars
+	}
+	overlap := max(c.OverlapLines, 0)
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(lines); {
+		end, size := start, 0
+		// Take at least one line, cutting the too long ones
+		for end < len(lines) && (end == start || size+len(lines[end]) <= maxChars) {
+			size += len(lines[end])
+			end++
+		}
+		text := strings.Join(lines[start:end], "")
+		if len(text) > maxChars {
+			text = strings.ToValidUTF8(text[:maxChars], "")
+		}
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{
+				Content:   text,
+				StartLine: start + 1,
+				EndLine:   end,
+			})
+		}
+		if end == len(lines) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return chunks
+}
@@ -3,6 +3,10 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	"github.com/Swarmind/libagent/pkg/config"
@@ -13,14 +17,14 @@ import (
 
 var DDGSearchDefinition = llms.FunctionDefinition{
 	Name: "webSearch",
-	Description: `A duckduckgo search wrapper.
-Given search query returns a multiple results with short descriptions and URLs.`,
+	Description: `A web search engine wrapper.
+Given search query returns a multiple ranked results with short descriptions and URLs.`,
 	Parameters: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
 			"query": map[string]any{
 				"type":        "string",
-				"description": "The duckduckgo search query",
+				"description": "The web search query",
 			},
 		},
 	},
@@ -30,8 +34,13 @@ type DDGSearchArgs struct {
 	Query string `json:"query"`
 }
 
+// DDGSearchTool is the webSearch tool, querying the configured search providers, DuckDuckGo by default.
 type DDGSearchTool struct {
-	wrappedTool *duckduckgo.Tool
+	Provider   SearchProvider
+	MaxResults int
+	// cache keeps the results by the provider, the results number and the normalized query if set
+	cache    ResponseCache
+	cacheTTL time.Duration
 }
 
 func (t DDGSearchTool) Call(ctx context.Context, input string) (string, error) {
@@ -39,7 +48,66 @@ func (t DDGSearchTool) Call(ctx context.Context, input string) (string, error) {
 	if err := json.Unmarshal([]byte(input), &ddgSearchArgs); err != nil {
 		return "", err
 	}
-	return t.wrappedTool.Call(ctx, ddgSearchArgs.Query)
+
+	key := fmt.Sprintf("webSearch %s n=%d %s", t.Provider.Name(), t.MaxResults, NormalizeQuery(ddgSearchArgs.Query))
+	return cachedCall(ctx, t.cache, t.cacheTTL, key, func() (string, error) {
+		results, err := t.Provider.Search(ctx, ddgSearchArgs.Query, t.MaxResults)
+		if err != nil {
+			return "", err
+		}
+		return RenderSearchResults(results), nil
+	})
+}
+
+// NewSearchProvider returns the configured providers chained with the fallback.
+func NewSearchProvider(cfg config.Config) (SearchProvider, error) {
+	names := cfg.WebSearchProviders
+	if len(names) == 0 {
+		names = []string{SearchProviderDuckDuckGo}
+	}
+	client := &http.Client{Timeout: DefaultSearchTimeout}
+
+	fallback := FallbackSearch{}
+	for _, name := range names {
+		var provider SearchProvider
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case SearchProviderDuckDuckGo:
+			userAgent := cfg.DDGSearchUserAgent
+			if userAgent == "" {
+				userAgent = duckduckgo.DefaultUserAgent
+			}
+			provider = DuckDuckGoSearch{UserAgent: userAgent, Client: client}
+		case SearchProviderSearxNG:
+			if cfg.WebSearchSearxNGURL == "" {
+				return nil, fmt.Errorf("searxng search empty URL")
+			}
+			provider = SearxNGSearch{BaseURL: cfg.WebSearchSearxNGURL, Client: client}
+		case SearchProviderBrave:
+			if cfg.WebSearchBraveAPIKey == "" {
+				return nil, fmt.Errorf("brave search empty API key")
+			}
+			provider = BraveSearch{APIKey: cfg.WebSearchBraveAPIKey, Client: client}
+		case SearchProviderBing:
+			if cfg.WebSearchBingAPIKey == "" {
+				return nil, fmt.Errorf("bing search empty API key")
+			}
+			provider = BingSearch{APIKey: cfg.WebSearchBingAPIKey, BaseURL: cfg.WebSearchBingURL, Client: client}
+		case SearchProviderGoogle:
+			if cfg.WebSearchGoogleAPIKey == "" || cfg.WebSearchGoogleEngineID == "" {
+				return nil, fmt.Errorf("google search empty API key or engine ID")
+			}
+			provider = GoogleSearch{APIKey: cfg.WebSearchGoogleAPIKey, EngineID: cfg.WebSearchGoogleEngineID, Client: client}
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("unknown search provider %q", name)
+		}
+		fallback.Providers = append(fallback.Providers, provider)
+	}
+	if len(fallback.Providers) == 1 {
+		return fallback.Providers[0], nil
+	}
+	return fallback, nil
 }
 
 func init() {
@@ -51,20 +119,22 @@ func init() {
 			if cfg.DDGSearchMaxResults == 0 {
 				cfg.DDGSearchMaxResults = 5
 			}
-			if cfg.DDGSearchUserAgent == "" {
-				cfg.DDGSearchUserAgent = duckduckgo.DefaultUserAgent
-			}
 
-			wrappedTool, err := duckduckgo.New(
-				cfg.DDGSearchMaxResults,
-				cfg.DDGSearchUserAgent,
-			)
+			provider, err := NewSearchProvider(cfg)
 			if err != nil {
 				return nil, err
 			}
 
+			cache, err := sharedResponseCache(ctx, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("ddg search: %w", err)
+			}
+
 			ddgSearchTool := DDGSearchTool{
-				wrappedTool: wrappedTool,
+				Provider:   provider,
+				MaxResults: cfg.DDGSearchMaxResults,
+				cache:      cache,
+				cacheTTL:   time.Duration(cfg.ToolCacheTTL) * time.Second,
 			}
 
 			return &tools.ToolData{
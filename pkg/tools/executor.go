@@ -3,15 +3,16 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	"github.com/Swarmind/libagent/pkg/config"
-	"github.com/ThomasRooney/gexpect"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
 	"github.com/tmc/langchaingo/llms"
@@ -20,31 +21,164 @@ import (
 const CommandNotesPromptAddition = `Important! List of host machine specific command usage recommendations:`
 const sendChunkSize = 512
 
+// DefaultCommandOutputLimit is the maximum size in bytes of the command result returned to the model.
+const DefaultCommandOutputLimit = 16 * 1024
+
+// DefaultCommandStreamLimit is the maximum size in bytes kept of every command output stream.
+const DefaultCommandStreamLimit = 1024 * 1024
+
+// DefaultCommandTimeout is the time after which the foreground command is interrupted.
+const DefaultCommandTimeout = 30 * time.Second
+
+const (
+	CommandActionRun           = "run"
+	CommandActionJobOutput     = "job_output"
+	CommandActionJobInput      = "job_input"
+	CommandActionJobKill       = "job_kill"
+	CommandActionSessionList   = "session_list"
+	CommandActionSessionClose  = "session_close"
+	CommandActionListArtifacts = "list_artifacts"
+)
+
 var CommandExecutorDefinition = llms.FunctionDefinition{
 	Name: "commandExecutor",
 	Description: `Executes a provided command in a interactive stateful bash shell session.
+Returns the exit code, duration, stdout and stderr of the command.
+Long running commands (servers, long builds) can be started with background=true, which returns a job_id.
+Use the job_output action to get the new job output and its state, job_input to write to the job stdin and job_kill to stop it.
+Commands can be run in separate named sessions (for example a server in one and a client in another), each with its own shell and working directory.
+Use session_list to list the sessions and session_close to close one.
+Use list_artifacts to list the files in the session working directory, for example to hand the built files back.
 Most likely all needed packages are preinstalled.`,
 	Parameters: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{
+					CommandActionRun,
+					CommandActionJobOutput,
+					CommandActionJobInput,
+					CommandActionJobKill,
+					CommandActionSessionList,
+					CommandActionSessionClose,
+					CommandActionListArtifacts,
+				},
+				"description": "the action to perform, run by default",
+			},
 			"command": map[string]any{
 				"type":        "string",
 				"description": "the shell command to execute to",
 			},
+			"timeout": map[string]any{
+				"type":        "integer",
+				"description": "optional timeout in seconds, after which the command is interrupted; for job_output - how long to wait for the job to finish",
+			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "run the command as a background job and return its job_id immediately",
+			},
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "the background job id for the job_* actions",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "the text to write to the job stdin for the job_input action",
+			},
+			"session": map[string]any{
+				"type":        "string",
+				"description": "optional shell session name, created on first use; " + DefaultCommandSession + " by default",
+			},
 		},
 	},
 }
 
 type CommandExecutorArgs struct {
-	Command string `json:"command"`
+	Action     string `json:"action,omitempty"`
+	Command    string `json:"command"`
+	Timeout    int    `json:"timeout,omitempty"`
+	Background bool   `json:"background,omitempty"`
+	JobID      string `json:"job_id,omitempty"`
+	Input      string `json:"input,omitempty"`
+	Session    string `json:"session,omitempty"`
+}
+
+// CommandResult is the outcome of a command executed in the shell session or as a background job.
+// ExitCode is -1 when the command did not finish and the exit status is unknown.
+// Truncated is set when the middle of the stdout or stderr was omitted by the stream limit.
+type CommandResult struct {
+	Command   string        `json:"command"`
+	Session   string        `json:"session,omitempty"`
+	JobID     string        `json:"job_id,omitempty"`
+	Running   bool          `json:"running,omitempty"`
+	Stdout    string        `json:"stdout"`
+	Stderr    string        `json:"stderr"`
+	ExitCode  int           `json:"exit_code"`
+	Duration  time.Duration `json:"duration"`
+	Truncated bool          `json:"truncated"`
+	TimedOut  bool          `json:"timed_out"`
 }
 
-// CommandExecutorTool represents a tool that executes commands using exec.Command.
+// String renders the result in the format presented to the model.
+func (r CommandResult) String() string {
+	sb := strings.Builder{}
+	if r.Session != "" && r.Session != DefaultCommandSession {
+		fmt.Fprintf(&sb, "session: %s\n", r.Session)
+	}
+	if r.JobID != "" {
+		fmt.Fprintf(&sb, "job_id: %s\n", r.JobID)
+		fmt.Fprintf(&sb, "running: %t\n", r.Running)
+	}
+	if !r.Running {
+		fmt.Fprintf(&sb, "exit_code: %d\n", r.ExitCode)
+	}
+	fmt.Fprintf(&sb, "duration: %s\n", r.Duration.Round(time.Millisecond))
+	if r.TimedOut {
+		sb.WriteString("timed_out: true\n")
+	}
+	if r.Truncated {
+		sb.WriteString("truncated: true\n")
+	}
+	if r.Stdout != "" {
+		fmt.Fprintf(&sb, "stdout:\n%s\n", strings.TrimSuffix(r.Stdout, "\n"))
+	}
+	if r.Stderr != "" {
+		fmt.Fprintf(&sb, "stderr:\n%s\n", strings.TrimSuffix(r.Stderr, "\n"))
+	}
+	if r.Stdout == "" && r.Stderr == "" {
+		sb.WriteString("(no output)\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// CommandExecutorTool represents a tool that executes commands in named bash shell sessions.
 type CommandExecutorTool struct {
-	tempDir *string
+	// StreamLimit is the maximum size in bytes kept of the stdout and stderr of every command
+	// and job output read, the middle of the longer output is omitted. Zero means DefaultCommandStreamLimit
+	StreamLimit int
+	Timeout     time.Duration
+	// IdleTimeout closes the sessions which were not used for the duration, zero disables it
+	IdleTimeout time.Duration
+	Sandbox     CommandSandbox
+	Workspace   CommandWorkspace
+	// TranscriptDir enables the session transcripts recording to the directory
+	TranscriptDir string
 
-	process *gexpect.ExpectSubprocess
-	prompt  string
+	sessionsMu  sync.Mutex
+	sessions    map[string]*commandSession
+	reaperStop  chan struct{}
+	transcripts []CommandTranscript
+
+	// workspaceMu guards the shared workspace preparation, which may take long for the git clone
+	workspaceMu sync.Mutex
+	// workspaceReady is set once the shared workspace directory is prepared
+	workspaceReady   bool
+	workspaceCreated bool
+
+	jobsMu sync.Mutex
+	jobs   map[string]*commandJob
+	jobSeq int
 }
 
 // Call executes the command with the given arguments.
@@ -53,81 +187,463 @@ func (s *CommandExecutorTool) Call(ctx context.Context, input string) (string, e
 	if err := json.Unmarshal([]byte(input), &commandExecutorArgs); err != nil {
 		return "", err
 	}
+	timeout := time.Duration(commandExecutorArgs.Timeout) * time.Second
+	session := commandExecutorArgs.Session
 
-	return s.RunCommand(commandExecutorArgs.Command)
-}
-
-func (s *CommandExecutorTool) RunCommand(input string) (string, error) {
-	if s.tempDir == nil {
-		tDir, err := os.MkdirTemp("", "libagent_command_executor_session_")
-		if err != nil {
+	var result CommandResult
+	var err error
+	switch commandExecutorArgs.Action {
+	case "", CommandActionRun:
+		if commandExecutorArgs.Background {
+			result, err = s.StartJob(session, commandExecutorArgs.Command)
+		} else {
+			result, err = s.RunCommandContext(ctx, session, commandExecutorArgs.Command, timeout)
+		}
+	case CommandActionJobOutput:
+		result, err = s.JobOutput(commandExecutorArgs.JobID, timeout)
+	case CommandActionJobInput:
+		result, err = s.JobInput(commandExecutorArgs.JobID, commandExecutorArgs.Input)
+	case CommandActionJobKill:
+		result, err = s.KillJob(commandExecutorArgs.JobID)
+	case CommandActionSessionList:
+		return renderSessions(s.Sessions()), nil
+	case CommandActionSessionClose:
+		if err := s.CloseSession(session); err != nil {
 			return "", err
 		}
-		log.Debug().Msgf("command executor temp directory %s created", tDir)
-		s.tempDir = &tDir
-
-		s.process, err = gexpect.SpawnAtDirectory("env -i bash --norc --noprofile", *s.tempDir)
+		return fmt.Sprintf("session %s closed", sessionName(session)), nil
+	case CommandActionListArtifacts:
+		artifacts, truncated, err := s.Artifacts(session)
 		if err != nil {
-			return "", fmt.Errorf("spawn: %w", err)
+			return "", err
 		}
-		// Start recording buffer
-		s.process.Capture()
-		// Expect default bash shell prompt end
-		s.process.Expect("$")
-
-		s.process.Send(fmt.Sprintf("export PATH=%s\n", os.Getenv("PATH")))
-		s.process.Send(fmt.Sprintf("export HOME=%s\n", os.Getenv("HOME")))
-		s.process.Send(fmt.Sprintf("export GOCACHE=%s\n", os.Getenv("GOCACHE")))
-		// Create a random UUID to set as a prompt to be sure that there are command end
-		s.prompt = uuid.New().String()
-		s.process.Send(fmt.Sprintf("PS1=%s\n", s.prompt))
-		// Expect sent command
-		s.process.Expect(fmt.Sprintf("PS1=%s", s.prompt))
-		// Expect changed prompt
-		s.process.Expect(s.prompt)
-		// Send raw mode
-		s.process.Send("stty raw\n")
-		s.process.Expect(s.prompt)
-		// Discard output by draining output buffer
-		s.process.Collect()
-	}
-
-	// Trim trailing '\' to avoid escaping last '\n' symbol
-	command := strings.TrimSuffix(input, `\`) + "\n"
-	log.Debug().Msgf("command executor: %s", strings.TrimSpace(command))
-
-	s.process.Capture()
-
-	err := s.process.Send(command)
+		return renderArtifacts(artifacts, truncated), nil
+	default:
+		return "", fmt.Errorf("unknown action %q", commandExecutorArgs.Action)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
+
+// RunCommand executes the command in the default session with the default timeout.
+func (s *CommandExecutorTool) RunCommand(input string) (CommandResult, error) {
+	return s.RunCommandContext(context.Background(), DefaultCommandSession, input, 0)
+}
+
+// RunCommandContext executes the command in the named session, creating it if needed.
+// The command is interrupted on timeout or context cancellation, zero timeout means the tool default.
+func (s *CommandExecutorTool) RunCommandContext(ctx context.Context, session, input string, timeout time.Duration) (CommandResult, error) {
+	if timeout <= 0 {
+		timeout = s.Timeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+
+	cs, err := s.session(ctx, session)
 	if err != nil {
-		log.Debug().Err(err).Msgf("send command")
+		return CommandResult{}, err
 	}
 
-	err = s.process.ExpectTimeout(s.prompt, time.Second*30)
+	log.Debug().Msgf("command executor [%s]: %s", cs.Name, strings.TrimSpace(input))
+
+	result, err := cs.run(ctx, input, timeout)
+	result.Session = cs.Name
+	s.record(cs.Name, TranscriptEventCommand, "", result)
+	s.limitStreams(&result)
+
+	log.Debug().
+		Str("session", cs.Name).
+		Int("exit_code", result.ExitCode).
+		Dur("duration", result.Duration).
+		Bool("timed_out", result.TimedOut).
+		Str("stdout", result.Stdout).
+		Str("stderr", result.Stderr).
+		Msg("command output")
+
+	return result, err
+}
+
+// StartJob starts the command as a background job in the current directory of the named session.
+func (s *CommandExecutorTool) StartJob(session, command string) (CommandResult, error) {
+	cs, err := s.session(context.Background(), session)
 	if err != nil {
-		s.process.Send(string([]byte{0x03}))
+		return CommandResult{}, err
 	}
+	dir := cs.cwd()
 
-	output := strings.TrimSpace(strings.ReplaceAll(string(s.process.Collect()), s.prompt, ""))
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
 
-	log.Debug().Msgf("command output: %s", output)
+	s.jobSeq++
+	id := fmt.Sprintf("job-%d", s.jobSeq)
+	job, err := startJob(id, cs.Name, command, dir, commandEnv(), s.Sandbox, []string{cs.dir}, s.streamLimit())
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if s.jobs == nil {
+		s.jobs = map[string]*commandJob{}
+	}
+	s.jobs[id] = job
 
-	return output, nil
+	result := CommandResult{
+		Command:  command,
+		Session:  cs.Name,
+		JobID:    id,
+		Running:  true,
+		ExitCode: -1,
+	}
+	s.record(cs.Name, TranscriptEventJobStart, "", result)
+	return result, nil
 }
 
-func (s *CommandExecutorTool) cleanup() error {
-	if s.tempDir == nil {
-		return nil
+// JobOutput returns the job output since the previous call, waiting up to wait duration for the job to finish.
+func (s *CommandExecutorTool) JobOutput(id string, wait time.Duration) (CommandResult, error) {
+	job, err := s.job(id)
+	if err != nil {
+		return CommandResult{}, err
 	}
+	result := job.output(wait)
+	s.record(job.Session, TranscriptEventJobOutput, "", result)
+	return result, nil
+}
 
-	log.Debug().Msgf("command executor remove temp directory and process shutdown %s", *s.tempDir)
-	err := os.RemoveAll(*s.tempDir)
-	s.tempDir = nil
+// JobInput writes the input to the job stdin.
+func (s *CommandExecutorTool) JobInput(id string, input string) (CommandResult, error) {
+	job, err := s.job(id)
 	if err != nil {
-		log.Warn().Err(err).Msg("Removing temp dir")
+		return CommandResult{}, err
+	}
+	if err := job.input(input); err != nil {
+		return CommandResult{}, err
+	}
+	result := job.output(0)
+	s.record(job.Session, TranscriptEventJobInput, input, result)
+	return result, nil
+}
+
+// KillJob terminates the job and returns its remaining output.
+func (s *CommandExecutorTool) KillJob(id string) (CommandResult, error) {
+	job, err := s.job(id)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	job.kill()
+
+	s.jobsMu.Lock()
+	delete(s.jobs, id)
+	s.jobsMu.Unlock()
+
+	result := job.output(0)
+	s.record(job.Session, TranscriptEventJobKill, "", result)
+	return result, nil
+}
+
+// Sessions returns the open sessions sorted by name.
+func (s *CommandExecutorTool) Sessions() []CommandSessionInfo {
+	s.sessionsMu.Lock()
+	sessions := make([]*commandSession, 0, len(s.sessions))
+	for _, cs := range s.sessions {
+		sessions = append(sessions, cs)
+	}
+	s.sessionsMu.Unlock()
+
+	jobs := map[string]int{}
+	s.jobsMu.Lock()
+	for _, job := range s.jobs {
+		if job.running() {
+			jobs[job.Session]++
+		}
+	}
+	s.jobsMu.Unlock()
+
+	infos := []CommandSessionInfo{}
+	for _, cs := range sessions {
+		info := cs.info()
+		info.Jobs = jobs[cs.Name]
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b CommandSessionInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// CloseSession kills the session jobs, shuts down its shell and removes its directory unless the workspace is kept.
+func (s *CommandExecutorTool) CloseSession(session string) error {
+	session = sessionName(session)
+
+	s.sessionsMu.Lock()
+	cs, ok := s.sessions[session]
+	delete(s.sessions, session)
+	s.sessionsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no such session %q", session)
+	}
+
+	return s.closeSession(cs)
+}
+
+func (s *CommandExecutorTool) closeSession(cs *commandSession) error {
+	s.jobsMu.Lock()
+	for id, job := range s.jobs {
+		if job.Session != cs.Name {
+			continue
+		}
+		job.kill()
+		delete(s.jobs, id)
 	}
+	s.jobsMu.Unlock()
 
-	return s.process.Close()
+	return cs.close()
+}
+
+func (s *CommandExecutorTool) session(ctx context.Context, name string) (*commandSession, error) {
+	name = sessionName(name)
+
+	s.sessionsMu.Lock()
+	cs, ok := s.sessions[name]
+	s.sessionsMu.Unlock()
+	if ok {
+		return cs, nil
+	}
+
+	if !sessionNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid session name %q, use up to 64 letters, digits, '-' or '_'", name)
+	}
+	// The directory is prepared without holding the sessions, the git clone may take long
+	dir, remove, err := s.sessionDir(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	if cs, ok := s.sessions[name]; ok {
+		// The session was created concurrently, the own new directory is not needed
+		if !s.Workspace.shared() {
+			os.RemoveAll(dir)
+		}
+		return cs, nil
+	}
+	cs = newCommandSession(name, dir, remove, s.Sandbox)
+	if s.TranscriptDir != "" {
+		cs.transcript, err = newTranscript(s.TranscriptDir, name)
+		if err != nil {
+			if remove {
+				os.RemoveAll(dir)
+			}
+			return nil, err
+		}
+		s.transcripts = append(s.transcripts, cs.transcript.CommandTranscript)
+		log.Debug().Msgf("command executor session %s transcript %s", name, cs.transcript.CastPath)
+	}
+	if s.sessions == nil {
+		s.sessions = map[string]*commandSession{}
+	}
+	s.sessions[name] = cs
+
+	if s.IdleTimeout > 0 && s.reaperStop == nil {
+		s.reaperStop = make(chan struct{})
+		go s.reapIdleSessions(s.reaperStop)
+	}
+
+	return cs, nil
+}
+
+// reapIdleSessions periodically closes the sessions idle for longer than IdleTimeout,
+// sessions with running jobs are kept.
+func (s *CommandExecutorTool) reapIdleSessions(stop chan struct{}) {
+	ticker := time.NewTicker(max(s.IdleTimeout/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		busy := map[string]bool{}
+		s.jobsMu.Lock()
+		for _, job := range s.jobs {
+			if job.running() {
+				busy[job.Session] = true
+			}
+		}
+		s.jobsMu.Unlock()
+
+		idle := []*commandSession{}
+		s.sessionsMu.Lock()
+		for name, cs := range s.sessions {
+			if busy[name] || !cs.idle(s.IdleTimeout) {
+				continue
+			}
+			delete(s.sessions, name)
+			idle = append(idle, cs)
+		}
+		s.sessionsMu.Unlock()
+
+		for _, cs := range idle {
+			log.Debug().Msgf("command executor session %s idle timeout", cs.Name)
+			if err := s.closeSession(cs); err != nil {
+				log.Warn().Err(err).Msgf("close idle session %s", cs.Name)
+			}
+		}
+	}
+}
+
+// Transcripts returns the transcripts recorded since the last cleanup, including the closed sessions ones.
+func (s *CommandExecutorTool) Transcripts() []CommandTranscript {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return slices.Clone(s.transcripts)
+}
+
+// record writes the event to the session transcript if it is enabled and the session is open.
+func (s *CommandExecutorTool) record(session, event, input string, result CommandResult) {
+	s.sessionsMu.Lock()
+	cs, ok := s.sessions[session]
+	s.sessionsMu.Unlock()
+	if !ok || cs.transcript == nil {
+		return
+	}
+	if err := cs.transcript.record(event, input, result); err != nil {
+		log.Warn().Err(err).Msgf("command executor session %s transcript", session)
+	}
+}
+
+func (s *CommandExecutorTool) job(id string) (*commandJob, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		ids := []string{}
+		for id := range s.jobs {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		return nil, fmt.Errorf("no such job %q, available jobs: %v", id, ids)
+	}
+	return job, nil
+}
+
+// limitStreams cuts the middle of the command stdout and stderr longer than the stream limit,
+// the job output is limited by its buffers.
+func (s *CommandExecutorTool) limitStreams(result *CommandResult) {
+	limit := s.streamLimit()
+	var truncated bool
+	result.Stdout, truncated = tools.LimitOutput(result.Stdout, limit, "")
+	result.Truncated = result.Truncated || truncated
+	result.Stderr, truncated = tools.LimitOutput(result.Stderr, limit, "")
+	result.Truncated = result.Truncated || truncated
+}
+
+func (s *CommandExecutorTool) streamLimit() int {
+	if s.StreamLimit <= 0 {
+		return DefaultCommandStreamLimit
+	}
+	return s.StreamLimit
+}
+
+// Cleanup kills all the jobs and closes all the sessions, logging the recorded transcripts.
+// The session directories and the shared workspace created by the tool are removed unless kept.
+// Use CleanupTranscripts to get the transcript paths.
+func (s *CommandExecutorTool) Cleanup() error {
+	transcripts, err := s.CleanupTranscripts()
+	for _, transcript := range transcripts {
+		log.Info().
+			Str("session", transcript.Session).
+			Str("cast", transcript.CastPath).
+			Str("log", transcript.LogPath).
+			Msg("command executor transcript")
+	}
+	return err
+}
+
+// CleanupTranscripts does the Cleanup and returns the transcripts recorded since the previous cleanup.
+// Call it on the CommandExecutor of the tools executor before its Cleanup.
+func (s *CommandExecutorTool) CleanupTranscripts() ([]CommandTranscript, error) {
+	s.sessionsMu.Lock()
+	sessions := s.sessions
+	transcripts := s.transcripts
+	s.sessions = nil
+	s.transcripts = nil
+	if s.reaperStop != nil {
+		close(s.reaperStop)
+		s.reaperStop = nil
+	}
+	s.sessionsMu.Unlock()
+
+	s.jobsMu.Lock()
+	for id, job := range s.jobs {
+		job.kill()
+		delete(s.jobs, id)
+	}
+	s.jobsMu.Unlock()
+
+	errs := []error{}
+	for _, cs := range sessions {
+		if err := cs.close(); err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", cs.Name, err))
+		}
+	}
+
+	s.workspaceMu.Lock()
+	if err := s.removeWorkspace(); err != nil {
+		errs = append(errs, fmt.Errorf("workspace: %w", err))
+	}
+	s.workspaceMu.Unlock()
+
+	return transcripts, errors.Join(errs...)
+}
+
+// CommandExecutor returns the command executor tool of the tools executor, nil if it is not enabled.
+func CommandExecutor(toolsExecutor *tools.ToolsExecutor) *CommandExecutorTool {
+	tool, ok := toolsExecutor.Tools[CommandExecutorDefinition.Name]
+	if !ok {
+		return nil
+	}
+	commandExecutorTool, _ := tool.Tool.(*CommandExecutorTool)
+	return commandExecutorTool
+}
+
+func sessionName(name string) string {
+	if name == "" {
+		return DefaultCommandSession
+	}
+	return name
+}
+
+func renderSessions(sessions []CommandSessionInfo) string {
+	if len(sessions) == 0 {
+		return "no open sessions"
+	}
+	sb := strings.Builder{}
+	for _, info := range sessions {
+		fmt.Fprintf(&sb, "- %s: cwd %s, jobs %d", info.Name, info.Cwd, info.Jobs)
+		if info.Busy {
+			sb.WriteString(", running a command")
+		} else {
+			fmt.Fprintf(&sb, ", idle %s", info.IdleTime.Round(time.Second))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// commandEnv returns the environment passed to the shell and background jobs.
+func commandEnv() []string {
+	return []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+		"GOCACHE=" + os.Getenv("GOCACHE"),
+	}
 }
 
 func init() {
@@ -136,8 +652,32 @@ func init() {
 			if cfg.CommandExecutorDisable {
 				return nil, nil
 			}
+			if cfg.CommandExecutorOutputLimit == 0 {
+				cfg.CommandExecutorOutputLimit = DefaultCommandOutputLimit
+			}
 
-			commandExecutorTool := CommandExecutorTool{}
+			// The result is limited by the tools executor, keeping the full output readable by pages
+			commandExecutorTool := &CommandExecutorTool{
+				StreamLimit: cfg.CommandExecutorStreamLimit,
+				Timeout:     time.Duration(cfg.CommandExecutorTimeout) * time.Second,
+				IdleTimeout: time.Duration(cfg.CommandExecutorSessionIdleTimeout) * time.Second,
+				Sandbox: CommandSandbox{
+					Enabled:      cfg.CommandExecutorSandbox,
+					Network:      cfg.CommandExecutorSandboxNetwork,
+					CPUSeconds:   cfg.CommandExecutorSandboxCPUSeconds,
+					MemoryMB:     cfg.CommandExecutorSandboxMemoryMB,
+					MaxProcesses: cfg.CommandExecutorSandboxMaxProcesses,
+					Seccomp:      cfg.CommandExecutorSandboxSeccomp,
+				},
+				TranscriptDir: cfg.CommandExecutorTranscriptDir,
+				Workspace: CommandWorkspace{
+					Dir:           cfg.CommandExecutorWorkspaceDir,
+					Keep:          cfg.CommandExecutorWorkspaceKeep,
+					Seed:          cfg.CommandExecutorWorkspaceSeed,
+					GitRepository: cfg.CommandExecutorWorkspaceGitRepository,
+					GitRef:        cfg.CommandExecutorWorkspaceGitRef,
+				},
+			}
 
 			definition := CommandExecutorDefinition
 			if len(cfg.CommandExecutorCommands) > 0 {
@@ -170,9 +710,11 @@ func init() {
 			}
 
 			return &tools.ToolData{
-				Definition: definition,
-				Call:       commandExecutorTool.Call,
-				Cleanup:    commandExecutorTool.cleanup,
+				Definition:  definition,
+				Call:        commandExecutorTool.Call,
+				Cleanup:     commandExecutorTool.Cleanup,
+				OutputLimit: cfg.CommandExecutorOutputLimit,
+				Tool:        commandExecutorTool,
 			}, nil
 		},
 	)
@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/rs/zerolog/log"
+)
+
+// Time to wait for the job to exit after SIGTERM, before it is killed with SIGKILL
+const jobKillGrace = 2 * time.Second
+
+// commandJob is a command running in the background in its own process group.
+// Its output is buffered until polled, every poll returns only the new output.
+type commandJob struct {
+	ID      string
+	Session string
+	Command string
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *jobBuffer
+	stderr  *jobBuffer
+	started time.Time
+	// sandboxed job command runs as the init of its PID namespace
+	sandboxed bool
+
+	done     chan struct{}
+	exitCode int
+	finished time.Time
+}
+
+// jobBuffer is a concurrent safe buffer, which is drained on every read.
+// It keeps up to limit bytes of the output since the last read: the head and the latest tail,
+// the bytes in the middle are dropped and reported by the omission marker.
+type jobBuffer struct {
+	mu      sync.Mutex
+	limit   int
+	head    []byte
+	tail    []byte
+	dropped int
+}
+
+func newJobBuffer(limit int) *jobBuffer {
+	if limit <= 0 {
+		limit = DefaultCommandStreamLimit
+	}
+	return &jobBuffer{limit: limit}
+}
+
+func (b *jobBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if len(b.tail) == 0 {
+		size := min(b.limit/2-len(b.head), len(p))
+		b.head = append(b.head, p[:size]...)
+		p = p[size:]
+	}
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - (b.limit - b.limit/2); over > 0 {
+		b.dropped += over
+		b.tail = append(b.tail[:0], b.tail[over:]...)
+	}
+	return n, nil
+}
+
+// drain returns the buffered output and whether its middle was dropped, then resets the buffer.
+func (b *jobBuffer) drain() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	head, tail, dropped := string(b.head), string(b.tail), b.dropped
+	b.head, b.tail, b.dropped = nil, nil, 0
+	if dropped == 0 {
+		return head + tail, false
+	}
+
+	// The runes split by the dropped middle are dropped too
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+		dropped++
+	}
+	if idx := lastRuneStart(head); idx != -1 && !utf8.FullRuneInString(head[idx:]) {
+		dropped += len(head) - idx
+		head = head[:idx]
+	}
+	return tools.OmitOutput(head, tail, dropped, ""), true
+}
+
+// lastRuneStart returns the index of the last rune start byte of the string, -1 if there is none.
+func lastRuneStart(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// startJob starts the command, keeping up to streamLimit bytes of its stdout and stderr between the output reads.
+func startJob(id, session, command, dir string, env []string, sandbox CommandSandbox, writable []string, streamLimit int) (*commandJob, error) {
+	job := &commandJob{
+		ID:       id,
+		Session:  session,
+		Command:  command,
+		stdout:   newJobBuffer(streamLimit),
+		stderr:   newJobBuffer(streamLimit),
+		done:     make(chan struct{}),
+		exitCode: -1,
+	}
+
+	job.cmd = exec.Command("bash", "-c", command)
+	job.cmd.Dir = dir
+	job.cmd.Env = sandbox.env(env)
+	job.cmd.Stdout = job.stdout
+	job.cmd.Stderr = job.stderr
+	job.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// Do not wait forever for the output of the orphaned processes holding the pipes
+	job.cmd.WaitDelay = time.Second
+	if sandbox.Enabled {
+		if err := sandbox.apply(job.cmd, writable); err != nil {
+			return nil, err
+		}
+		job.sandboxed = true
+	}
+
+	stdin, err := job.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	job.stdin = stdin
+
+	if err := job.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start job: %w", err)
+	}
+	job.started = time.Now()
+	log.Debug().Msgf("command executor job %s started: %s", id, command)
+
+	go func() {
+		err := job.cmd.Wait()
+		job.finished = time.Now()
+		if exitErr := (&exec.ExitError{}); errors.As(err, &exitErr) {
+			job.exitCode = exitErr.ExitCode()
+		} else if err == nil {
+			job.exitCode = 0
+		}
+		log.Debug().Msgf("command executor job %s finished with exit code %d", id, job.exitCode)
+		close(job.done)
+	}()
+
+	return job, nil
+}
+
+// output waits up to the wait duration for the job to finish
+// and returns the output collected since the previous call.
+func (j *commandJob) output(wait time.Duration) CommandResult {
+	if wait > 0 {
+		select {
+		case <-j.done:
+		case <-time.After(wait):
+		}
+	}
+
+	result := CommandResult{
+		Command:  j.Command,
+		Session:  j.Session,
+		JobID:    j.ID,
+		ExitCode: -1,
+		Running:  true,
+	}
+	select {
+	case <-j.done:
+		result.Running = false
+		result.ExitCode = j.exitCode
+		result.Duration = j.finished.Sub(j.started)
+	default:
+		result.Duration = time.Since(j.started)
+	}
+	var truncated bool
+	result.Stdout, truncated = j.stdout.drain()
+	result.Truncated = truncated
+	result.Stderr, truncated = j.stderr.drain()
+	result.Truncated = result.Truncated || truncated
+
+	return result
+}
+
+func (j *commandJob) running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (j *commandJob) input(data string) error {
+	select {
+	case <-j.done:
+		return fmt.Errorf("job %s is not running", j.ID)
+	default:
+	}
+	_, err := io.WriteString(j.stdin, data)
+	return err
+}
+
+// kill terminates the whole job process group, escalating to SIGKILL after the grace period.
+// The sandboxed job is killed right away: its command is the PID namespace init, which ignores
+// SIGTERM from the host without a handler, and killing it takes down the whole namespace.
+func (j *commandJob) kill() {
+	select {
+	case <-j.done:
+		return
+	default:
+	}
+
+	pgid := j.cmd.Process.Pid
+	if j.sandboxed {
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+			log.Warn().Err(err).Msgf("kill job %s", j.ID)
+		}
+		<-j.done
+		return
+	}
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		log.Warn().Err(err).Msgf("terminate job %s", j.ID)
+	}
+	select {
+	case <-j.done:
+	case <-time.After(jobKillGrace):
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+			log.Warn().Err(err).Msgf("kill job %s", j.ID)
+		}
+		<-j.done
+	}
+}
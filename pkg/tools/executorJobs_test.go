@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{"empty", nil, "", false},
+		{"under the limit", []string{"abc", "def"}, "abcdef", false},
+		{"at the limit", []string{"0123456789"}, "0123456789", false},
+		{"over the limit", []string{"01234", "56789", "abcde"}, "01234\n... [5 bytes omitted] ...\nabcde", true},
+		{"small writes", strings.Split("0123456789abcdefghij", ""), "01234\n... [10 bytes omitted] ...\nfghij", true},
+		{"split runes", []string{"ab", "ccé", "ééé", "éxyzw"}, "abcc\n... [10 bytes omitted] ...\nxyzw", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newJobBuffer(10)
+			for _, data := range tt.writes {
+				if n, err := buffer.Write([]byte(data)); n != len(data) || err != nil {
+					t.Fatalf("write %d of %d bytes: %v", n, len(data), err)
+				}
+			}
+			got, truncated := buffer.drain()
+			if got != tt.want || truncated != tt.truncated {
+				t.Errorf("output %q truncated %t, want %q truncated %t", got, truncated, tt.want, tt.truncated)
+			}
+			if got, _ := buffer.drain(); got != "" {
+				t.Errorf("output %q after the drain", got)
+			}
+		})
+	}
+}
+
+// waitJob polls the job output until it finishes, returning all of its output.
+func waitJob(t *testing.T, executor *CommandExecutorTool, id string) CommandResult {
+	t.Helper()
+	result := CommandResult{}
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		output, err := executor.JobOutput(id, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.Stdout = result.Stdout + output.Stdout
+		output.Stderr = result.Stderr + output.Stderr
+		output.Truncated = result.Truncated || output.Truncated
+		result = output
+		if !result.Running {
+			return result
+		}
+	}
+	t.Fatalf("job %s did not finish", id)
+	return result
+}
+
+func TestCommandJobs(t *testing.T) {
+	executor := &CommandExecutorTool{}
+	defer executor.Cleanup()
+
+	t.Run("poll", func(t *testing.T) {
+		started, err := executor.StartJob("", "echo one; sleep 0.3; echo two; echo err >&2; exit 3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !started.Running || started.JobID == "" {
+			t.Fatalf("job %+v, want the running job", started)
+		}
+
+		// The first poll without waiting returns the output so far
+		time.Sleep(100 * time.Millisecond)
+		first, err := executor.JobOutput(started.JobID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !first.Running || first.ExitCode != -1 || first.Stdout != "one\n" {
+			t.Errorf("first poll %+v, want the running job with its first line", first)
+		}
+
+		second, err := executor.JobOutput(started.JobID, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second.Running || second.ExitCode != 3 || second.Stdout != "two\n" || second.Stderr != "err\n" {
+			t.Errorf("second poll %+v, want the finished job with only the new output", second)
+		}
+		if second.Duration < 300*time.Millisecond {
+			t.Errorf("duration %s, want the job run time", second.Duration)
+		}
+	})
+
+	t.Run("input", func(t *testing.T) {
+		started, err := executor.StartJob("", "read line; echo got $line")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := executor.JobInput(started.JobID, "hello\n"); err != nil {
+			t.Fatal(err)
+		}
+		result := waitJob(t, executor, started.JobID)
+		if result.ExitCode != 0 || result.Stdout != "got hello\n" {
+			t.Errorf("result %+v, want the echoed input", result)
+		}
+		if _, err := executor.JobInput(started.JobID, "again\n"); err == nil {
+			t.Errorf("input to the finished job succeeded")
+		}
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		started, err := executor.StartJob("", "echo started; sleep 60")
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		start := time.Now()
+		result, err := executor.KillJob(started.JobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) >= jobKillGrace {
+			t.Errorf("kill waited for the grace period")
+		}
+		if result.Running || result.Stdout != "started\n" {
+			t.Errorf("result %+v, want the stopped job with its output", result)
+		}
+		if _, err := executor.JobOutput(started.JobID, 0); err == nil {
+			t.Errorf("killed job is still listed")
+		}
+	})
+
+	t.Run("chatty", func(t *testing.T) {
+		executor := &CommandExecutorTool{StreamLimit: 1024}
+		defer executor.Cleanup()
+		started, err := executor.StartJob("", "for i in $(seq 10000); do echo line $i; done")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := waitJob(t, executor, started.JobID)
+		if !result.Truncated || !strings.Contains(result.Stdout, "bytes omitted") {
+			t.Errorf("result is not truncated, %d bytes of stdout", len(result.Stdout))
+		}
+		if !strings.HasSuffix(result.Stdout, "line 10000\n") {
+			t.Errorf("stdout %q, want the latest output kept", result.Stdout[max(len(result.Stdout)-64, 0):])
+		}
+		if !strings.Contains(result.String(), "truncated: true") {
+			t.Errorf("result %q, want the truncation reported", result.String())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := executor.JobOutput("job-1000", 0); err == nil {
+			t.Errorf("output of the unknown job succeeded")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxSpecEnv is the environment variable which switches the re-executed binary into the sandbox init mode.
+const sandboxSpecEnv = "LIBAGENT_SANDBOX_SPEC"
+
+// CommandSandbox configures the isolation of the shell sessions and background jobs.
+// The sandboxed process sees the host root with all of its submounts read-only and a private /tmp,
+// only the session directory is writable. Every shell and background job gets its own namespaces,
+// so without Network they can not reach each other over the loopback.
+type CommandSandbox struct {
+	Enabled bool `json:"enabled"`
+	// Network keeps the host network, otherwise only the loopback interface is available
+	Network bool `json:"network"`
+	// CPUSeconds, MemoryMB and MaxProcesses limit every sandboxed process, zero means unlimited
+	CPUSeconds   int `json:"cpu_seconds"`
+	MemoryMB     int `json:"memory_mb"`
+	MaxProcesses int `json:"max_processes"`
+	// Seccomp denies the system calls which are not needed by the regular commands,
+	// like mount, ptrace, kexec_load or namespaces creation
+	Seccomp bool `json:"seccomp"`
+}
+
+// sandboxSpec is passed to the sandbox init to set up the environment and execute the command.
+type sandboxSpec struct {
+	Sandbox  CommandSandbox `json:"sandbox"`
+	Root     string         `json:"root"`
+	Path     string         `json:"path"`
+	Args     []string       `json:"args"`
+	Dir      string         `json:"dir"`
+	Writable []string       `json:"writable"`
+}
+
+// sandboxRoot is the mount point of the sandbox root, every sandbox mounts it in its own mount namespace.
+func sandboxRoot() string {
+	return filepath.Join(os.TempDir(), "libagent_sandbox_root")
+}
+
+// env replaces the variables pointing to the read-only host directories.
+func (sb CommandSandbox) env(env []string) []string {
+	if !sb.Enabled {
+		return env
+	}
+	result := []string{}
+	for _, v := range env {
+		if strings.HasPrefix(v, "HOME=") || strings.HasPrefix(v, "GOCACHE=") {
+			continue
+		}
+		result = append(result, v)
+	}
+	return append(result,
+		"HOME=/tmp",
+		"GOCACHE=/tmp/.cache/go-build",
+	)
+}
@@ -0,0 +1,406 @@
+//go:build linux
+
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Secure bits preventing the root user inside the sandbox to regain the dropped capabilities on exec
+const (
+	secbitNoRoot              = 1 << 0
+	secbitNoRootLocked        = 1 << 1
+	secbitNoSetuidFixup       = 1 << 2
+	secbitNoSetuidFixupLocked = 1 << 3
+)
+
+// Namespace creation flags denied for clone by the seccomp filter
+const cloneNamespaceFlags = unix.CLONE_NEWNS | unix.CLONE_NEWUTS | unix.CLONE_NEWIPC |
+	unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWNET | unix.CLONE_NEWCGROUP
+
+var seccompDeniedSyscalls = []uint32{
+	unix.SYS_PTRACE,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_FSOPEN,
+	unix.SYS_FSMOUNT,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_REBOOT,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+}
+
+func init() {
+	if spec, ok := os.LookupEnv(sandboxSpecEnv); ok {
+		sandboxInit(spec)
+	}
+}
+
+// apply makes the command to be started through the sandbox init in the new namespaces.
+// The writable paths are bind mounted read-write into the sandbox at the same locations.
+func (sb CommandSandbox) apply(cmd *exec.Cmd, writable []string) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("sandbox executable: %w", err)
+	}
+
+	spec := sandboxSpec{
+		Sandbox:  sb,
+		Root:     sandboxRoot(),
+		Path:     cmd.Path,
+		Args:     cmd.Args,
+		Dir:      cmd.Dir,
+		Writable: writable,
+	}
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	cmd.Path = exe
+	cmd.Args = []string{"libagent-sandbox"}
+	// The directory is changed inside the sandbox, it may not exist at the host
+	cmd.Dir = "/"
+	cmd.Env = append(slices.Clone(cmd.Env), sandboxSpecEnv+"="+string(specBytes))
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS |
+		syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !sb.Network {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	// Map the current user to root inside the user namespace to be able to set up the mounts,
+	// the capabilities are dropped before the command execution
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+
+	return nil
+}
+
+// sandboxInit runs in the re-executed binary inside the new namespaces, it never returns.
+func sandboxInit(specJSON string) {
+	// Keep the thread, since the seccomp filter is attached to the thread which executes the command
+	runtime.LockOSThread()
+
+	spec := sandboxSpec{}
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		sandboxFail("spec", err)
+	}
+
+	if err := setupSandboxMounts(spec); err != nil {
+		sandboxFail("mounts", err)
+	}
+	if !spec.Sandbox.Network {
+		if err := loopbackUp(); err != nil {
+			sandboxFail("loopback", err)
+		}
+	}
+	if err := setSandboxRlimits(spec.Sandbox); err != nil {
+		sandboxFail("rlimits", err)
+	}
+	if err := dropCapabilities(); err != nil {
+		sandboxFail("capabilities", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		sandboxFail("no new privs", err)
+	}
+	if spec.Sandbox.Seccomp {
+		if err := installSeccompFilter(); err != nil {
+			sandboxFail("seccomp", err)
+		}
+	}
+
+	env := []string{}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, sandboxSpecEnv+"=") {
+			env = append(env, v)
+		}
+	}
+	sandboxFail("exec", syscall.Exec(spec.Path, spec.Args, env))
+}
+
+func sandboxFail(stage string, err error) {
+	fmt.Fprintf(os.Stderr, "libagent sandbox %s: %v\n", stage, err)
+	os.Exit(127)
+}
+
+func setupSandboxMounts(spec sandboxSpec) error {
+	// Do not propagate any mount changes back to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	root := spec.Root
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return err
+	}
+	if err := unix.Mount("/", root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind host root: %w", err)
+	}
+	if err := remountReadOnly(root); err != nil {
+		return fmt.Errorf("remount host root read-only: %w", err)
+	}
+
+	if err := unix.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+
+	for _, path := range spec.Writable {
+		target := filepath.Join(root, path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE, 0o644)
+			if err != nil {
+				return err
+			}
+			f.Close()
+		}
+		if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", path, err)
+		}
+	}
+
+	// The fresh proc shows only the sandbox processes
+	if err := unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+
+	if err := unix.Chroot(root); err != nil {
+		return fmt.Errorf("chroot: %w", err)
+	}
+	dir := spec.Dir
+	if dir == "" {
+		dir = "/"
+	}
+	return unix.Chdir(dir)
+}
+
+// remountReadOnly makes the mount at the path read-only together with all of its submounts.
+func remountReadOnly(path string) error {
+	err := unix.MountSetattr(unix.AT_FDCWD, path, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+
+	// The kernels before 5.12 have no mount_setattr, so every submount is remounted
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	for _, mount := range parseMountPoints(data, path) {
+		// The locked flags of the original mount have to be kept on remount inside the user namespace
+		flags, err := mountFlags(mount)
+		if err != nil {
+			return err
+		}
+		if err := unix.Mount("", mount, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|flags, ""); err != nil {
+			return fmt.Errorf("%s: %w", mount, err)
+		}
+	}
+	return nil
+}
+
+// parseMountPoints returns the mount points of the mountinfo at or under the prefix path, parents first.
+func parseMountPoints(mountinfo []byte, prefix string) []string {
+	var mounts []string
+	for _, line := range strings.Split(string(mountinfo), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		// The spaces and other special characters are escaped as octal codes
+		mount := fields[4]
+		for _, escape := range [][2]string{{`\040`, " "}, {`\011`, "\t"}, {`\012`, "\n"}, {`\134`, `\`}} {
+			mount = strings.ReplaceAll(mount, escape[0], escape[1])
+		}
+		if prefix == "/" || mount == prefix || strings.HasPrefix(mount, prefix+"/") {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+func mountFlags(path string) (uintptr, error) {
+	stat := unix.Statfs_t{}
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	var flags uintptr
+	for st, ms := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(stat.Flags)&st != 0 {
+			flags |= ms
+		}
+	}
+	return flags, nil
+}
+
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return err
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq)
+}
+
+func setSandboxRlimits(sb CommandSandbox) error {
+	limits := map[int]uint64{}
+	if sb.CPUSeconds > 0 {
+		limits[unix.RLIMIT_CPU] = uint64(sb.CPUSeconds)
+	}
+	if sb.MemoryMB > 0 {
+		limits[unix.RLIMIT_AS] = uint64(sb.MemoryMB) * 1024 * 1024
+	}
+	if sb.MaxProcesses > 0 {
+		limits[unix.RLIMIT_NPROC] = uint64(sb.MaxProcesses)
+	}
+	for resource, limit := range limits {
+		if err := unix.Setrlimit(resource, &unix.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return fmt.Errorf("resource %d: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// dropCapabilities clears all the capabilities of the namespace root user and locks them from being regained.
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS,
+		secbitNoRoot|secbitNoRootLocked|secbitNoSetuidFixup|secbitNoSetuidFixupLocked,
+		0, 0, 0,
+	); err != nil {
+		return fmt.Errorf("securebits: %w", err)
+	}
+	for capability := 0; capability <= unix.CAP_LAST_CAP; capability++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil && err != unix.EINVAL {
+			return fmt.Errorf("bounding set: %w", err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && err != unix.EINVAL {
+		return fmt.Errorf("ambient set: %w", err)
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	return unix.Capset(&header, &data[0])
+}
+
+// installSeccompFilter denies the system calls from the seccompDeniedSyscalls list and the namespaces creation with EPERM.
+// clone3 is denied with ENOSYS, since its flags can not be inspected, so the libc falls back to clone.
+func installSeccompFilter() error {
+	var arch uint32
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = unix.AUDIT_ARCH_X86_64
+	case "arm64":
+		arch = unix.AUDIT_ARCH_AARCH64
+	default:
+		return fmt.Errorf("unsupported architecture %s", runtime.GOARCH)
+	}
+
+	const (
+		offsetNr   = 0
+		offsetArch = 4
+		offsetArg0 = 16
+	)
+	retErrno := func(errno unix.Errno) unix.SockFilter {
+		return bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(errno))
+	}
+
+	filter := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArch),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		retErrno(unix.EPERM),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetNr),
+	}
+	for _, nr := range seccompDeniedSyscalls {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			retErrno(unix.EPERM),
+		)
+	}
+	filter = append(filter,
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE3, 0, 1),
+		retErrno(unix.ENOSYS),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE, 0, 3),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArg0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, cloneNamespaceFlags, 0, 1),
+		retErrno(unix.EPERM),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+	)
+
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
@@ -0,0 +1,246 @@
+//go:build linux
+
+package tools
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxSyscallEnv makes the test binary run as the syscall probe inside the sandbox
+const sandboxSyscallEnv = "LIBAGENT_SANDBOX_TEST_SYSCALL"
+
+// runSandboxJob runs the command in the sandbox in the dir and waits for its result.
+func runSandboxJob(t *testing.T, sandbox CommandSandbox, dir, command string, env ...string) CommandResult {
+	t.Helper()
+	sandbox.Enabled = true
+	job, err := startJob("test", "test", command, dir, append(commandEnv(), env...), sandbox, []string{dir}, 0)
+	if err != nil {
+		t.Fatalf("start job: %v", err)
+	}
+	result := job.output(10 * time.Second)
+	if result.Running {
+		job.kill()
+		t.Fatalf("job %q did not finish", command)
+	}
+	return result
+}
+
+// skipWithoutSandbox skips the test if the user namespaces are not available.
+func skipWithoutSandbox(t *testing.T) {
+	t.Helper()
+	result := runSandboxJob(t, CommandSandbox{}, t.TempDir(), "true")
+	if result.ExitCode != 0 {
+		t.Skipf("sandbox is not available: %s", strings.TrimSpace(result.Stderr))
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	skipWithoutSandbox(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	connect := fmt.Sprintf("echo > /dev/tcp/127.0.0.1/%d", listener.Addr().(*net.TCPAddr).Port)
+
+	tests := []struct {
+		name     string
+		network  bool
+		exitCode int
+	}{
+		{"denied", false, 1},
+		{"allowed", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runSandboxJob(t, CommandSandbox{Network: tt.network}, t.TempDir(), connect)
+			if result.ExitCode != tt.exitCode {
+				t.Errorf("exit code %d, want %d, stderr: %s", result.ExitCode, tt.exitCode, result.Stderr)
+			}
+		})
+	}
+}
+
+func TestSandboxRlimits(t *testing.T) {
+	skipWithoutSandbox(t)
+
+	result := runSandboxJob(t,
+		CommandSandbox{CPUSeconds: 7, MemoryMB: 256, MaxProcesses: 64}, t.TempDir(),
+		"ulimit -t; ulimit -v; ulimit -u",
+	)
+	if got, want := strings.Fields(result.Stdout), []string{"7", "262144", "64"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("limits %v, want %v", got, want)
+	}
+
+	result = runSandboxJob(t, CommandSandbox{MemoryMB: 16}, t.TempDir(), "x=$(head -c 64m /dev/zero | tr '\\0' x)")
+	if result.ExitCode == 0 {
+		t.Errorf("64MB allocation succeeded with the 16MB limit")
+	}
+
+	// The process limit is not enforced for the host root
+	if os.Getuid() != 0 {
+		result = runSandboxJob(t, CommandSandbox{MaxProcesses: 1}, t.TempDir(), "true & wait")
+		if result.ExitCode == 0 {
+			t.Errorf("fork succeeded with the process limit")
+		}
+	}
+}
+
+func TestSandboxSeccomp(t *testing.T) {
+	skipWithoutSandbox(t)
+
+	// The host /tmp holding the test binary is hidden in the sandbox, so it is copied to the writable dir
+	dir := t.TempDir()
+	binary, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "probe"), binary, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	probe := "./probe -test.run=^TestSandboxSyscallProbe$"
+	tests := []struct {
+		name    string
+		seccomp bool
+		errno   string
+	}{
+		{"blocked", true, unix.EPERM.Error()},
+		{"allowed", false, unix.EOPNOTSUPP.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runSandboxJob(t, CommandSandbox{Seccomp: tt.seccomp}, dir, probe, sandboxSyscallEnv+"=1")
+			if !strings.Contains(result.Stdout, "keyctl: "+tt.errno) {
+				t.Errorf("probe output %q, want keyctl: %s", result.Stdout, tt.errno)
+			}
+		})
+	}
+}
+
+// TestSandboxSyscallProbe is run by TestSandboxSeccomp inside the sandbox.
+func TestSandboxSyscallProbe(t *testing.T) {
+	if os.Getenv(sandboxSyscallEnv) == "" {
+		t.Skip("run by TestSandboxSeccomp")
+	}
+	// The unknown keyctl operation fails with EOPNOTSUPP, unless the call is denied
+	_, _, errno := unix.Syscall(unix.SYS_KEYCTL, 9999, 0, 0)
+	fmt.Printf("keyctl: %v\n", errno)
+}
+
+// writableSubmounts returns the host mount points under the root, other than the sandbox replaced ones,
+// where the test can create a file.
+func writableSubmounts(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := []string{}
+	for _, mount := range parseMountPoints(data, "/") {
+		if mount == "/" || mount == "/proc" || strings.HasPrefix(mount, "/proc/") ||
+			mount == os.TempDir() || strings.HasPrefix(mount, os.TempDir()+"/") || slices.Contains(mounts, mount) {
+			continue
+		}
+		f, err := os.CreateTemp(mount, "libagent_sandbox_test_")
+		if err != nil {
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+func TestSandboxReadOnlySubmounts(t *testing.T) {
+	skipWithoutSandbox(t)
+
+	mounts := writableSubmounts(t)
+	if len(mounts) == 0 {
+		t.Skip("no writable host submounts")
+	}
+	for _, mount := range mounts {
+		t.Run(mount, func(t *testing.T) {
+			path := filepath.Join(mount, fmt.Sprintf("libagent_sandbox_test_%d", time.Now().UnixNano()))
+			defer os.Remove(path)
+
+			result := runSandboxJob(t, CommandSandbox{}, t.TempDir(), "echo x > "+shellQuote(path))
+			if result.ExitCode == 0 || !strings.Contains(strings.ToLower(result.Stderr), unix.EROFS.Error()) {
+				t.Errorf("exit code %d, stderr %q, want %s", result.ExitCode, result.Stderr, unix.EROFS)
+			}
+			if _, err := os.Stat(path); err == nil {
+				t.Errorf("file %s written by the sandbox on the host", path)
+			}
+		})
+	}
+
+	// The writable dir and /tmp stay writable
+	result := runSandboxJob(t, CommandSandbox{}, t.TempDir(), "echo x > file && echo x > /tmp/file")
+	if result.ExitCode != 0 {
+		t.Errorf("write to the writable dirs: exit code %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+}
+
+func TestParseMountPoints(t *testing.T) {
+	data := []byte(`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /dev/shm rw,nosuid,nodev shared:2 - tmpfs tmpfs rw
+24 22 0:22 / /mnt/my\040disk rw - ext4 /dev/sdb1 rw
+25 22 0:23 / /tmp/libagent_sandbox_root rw - ext4 /dev/sda1 rw
+26 25 0:24 / /tmp/libagent_sandbox_root/dev/shm rw - tmpfs tmpfs rw
+`)
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"/", []string{"/", "/dev/shm", "/mnt/my disk", "/tmp/libagent_sandbox_root", "/tmp/libagent_sandbox_root/dev/shm"}},
+		{"/tmp/libagent_sandbox_root", []string{"/tmp/libagent_sandbox_root", "/tmp/libagent_sandbox_root/dev/shm"}},
+		{"/mnt/my", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := parseMountPoints(data, tt.prefix); !slices.Equal(got, tt.want) {
+				t.Errorf("mount points %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSandboxJobKill(t *testing.T) {
+	skipWithoutSandbox(t)
+
+	dir := t.TempDir()
+	// The command is the init of the PID namespace, which ignores SIGTERM
+	job, err := startJob("test", "test", "while true; do sleep 1; done", dir, commandEnv(), CommandSandbox{Enabled: true}, []string{dir}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	job.kill()
+	if elapsed := time.Since(start); elapsed >= jobKillGrace {
+		t.Errorf("kill took %s, the grace period is %s", elapsed, jobKillGrace)
+	}
+	if job.running() {
+		t.Errorf("job is running after kill")
+	}
+}
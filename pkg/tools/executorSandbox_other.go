@@ -0,0 +1,12 @@
+//go:build !linux
+
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func (sb CommandSandbox) apply(cmd *exec.Cmd, writable []string) error {
+	return fmt.Errorf("command sandbox is supported on linux only")
+}
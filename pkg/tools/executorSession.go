@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCommandSession is the session used when the session name is not provided.
+const DefaultCommandSession = "default"
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// commandSession is a named shell session with its own working directory.
+// The shell is started lazily and restarted in the same directory if it was terminated.
+type commandSession struct {
+	Name    string
+	Sandbox CommandSandbox
+	// remove is set when the session directory is removed on close
+	remove bool
+	// transcript records the session commands if enabled
+	transcript *transcript
+
+	// mu serializes the commands executed in the session shell
+	mu       sync.Mutex
+	dir      string
+	lastUsed time.Time
+
+	// shell is swapped out on close without waiting for mu, to abort the running command
+	shell  atomic.Pointer[shell]
+	closed atomic.Bool
+}
+
+// CommandSessionInfo describes the shell session state.
+type CommandSessionInfo struct {
+	Name     string        `json:"name"`
+	Dir      string        `json:"dir"`
+	Cwd      string        `json:"cwd"`
+	Busy     bool          `json:"busy"`
+	IdleTime time.Duration `json:"idle_time"`
+	Jobs     int           `json:"jobs"`
+}
+
+func newCommandSession(name, dir string, remove bool, sandbox CommandSandbox) *commandSession {
+	return &commandSession{
+		Name:     name,
+		Sandbox:  sandbox,
+		remove:   remove,
+		dir:      dir,
+		lastUsed: time.Now(),
+	}
+}
+
+func (cs *commandSession) run(ctx context.Context, command string, timeout time.Duration) (CommandResult, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	defer func() { cs.lastUsed = time.Now() }()
+
+	sh, err := cs.ensureShell(ctx)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	if cs.transcript != nil {
+		if err := cs.transcript.commandStarted(command); err != nil {
+			log.Warn().Err(err).Msgf("command executor session %s transcript", cs.Name)
+		}
+	}
+	result, err := sh.run(ctx, command, timeout)
+	if errors.Is(err, ErrShellTerminated) {
+		if cs.closed.Load() {
+			return result, fmt.Errorf("session %s closed", cs.Name)
+		}
+		// The shell will be restarted in the same directory on the next command
+		log.Warn().Err(err).Msgf("command executor session %s shell", cs.Name)
+		if closeErr := sh.close(); closeErr != nil {
+			log.Warn().Err(closeErr).Msg("close terminated shell")
+		}
+		cs.shell.CompareAndSwap(sh, nil)
+		err = ctx.Err()
+	}
+	return result, err
+}
+
+// cwd returns the current working directory of the session shell, falling back to the session directory.
+func (cs *commandSession) cwd() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.lastUsed = time.Now()
+
+	if sh := cs.shell.Load(); sh != nil {
+		if dir := sh.cwd(); dir != "" {
+			return dir
+		}
+	}
+	return cs.dir
+}
+
+func (cs *commandSession) ensureShell(ctx context.Context) (*shell, error) {
+	if cs.closed.Load() {
+		return nil, fmt.Errorf("session %s closed", cs.Name)
+	}
+	if sh := cs.shell.Load(); sh != nil {
+		return sh, nil
+	}
+	sh, err := startShell(ctx, cs.dir, commandEnv(), cs.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+	cs.shell.Store(sh)
+	return sh, nil
+}
+
+// idle reports whether the session was not used for the timeout duration and no command is running.
+func (cs *commandSession) idle(timeout time.Duration) bool {
+	if !cs.mu.TryLock() {
+		return false
+	}
+	defer cs.mu.Unlock()
+	return time.Since(cs.lastUsed) >= timeout
+}
+
+func (cs *commandSession) info() CommandSessionInfo {
+	info := CommandSessionInfo{
+		Name: cs.Name,
+		Dir:  cs.dir,
+		Cwd:  cs.dir,
+		Busy: true,
+	}
+	if cs.mu.TryLock() {
+		defer cs.mu.Unlock()
+		info.Busy = false
+		info.IdleTime = time.Since(cs.lastUsed)
+		if sh := cs.shell.Load(); sh != nil {
+			if dir := sh.cwd(); dir != "" {
+				info.Cwd = dir
+			}
+		}
+	}
+	return info
+}
+
+// close shuts down the session shell, aborting the running command, closes the transcript
+// and removes the session directory if owned.
+func (cs *commandSession) close() error {
+	if cs.closed.Swap(true) {
+		return nil
+	}
+
+	var err error
+	if sh := cs.shell.Swap(nil); sh != nil {
+		err = sh.close()
+	}
+
+	// Wait for the aborted command to return
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.transcript != nil {
+		if closeErr := cs.transcript.close(); closeErr != nil {
+			log.Warn().Err(closeErr).Msgf("close session %s transcript", cs.Name)
+		}
+	}
+
+	if !cs.remove {
+		log.Debug().Msgf("command executor session %s process shutdown, directory %s kept", cs.Name, cs.dir)
+		return err
+	}
+	log.Debug().Msgf("command executor session %s remove temp directory and process shutdown %s", cs.Name, cs.dir)
+	if rmErr := os.RemoveAll(cs.dir); rmErr != nil {
+		log.Warn().Err(rmErr).Msg("Removing temp dir")
+	}
+
+	return err
+}
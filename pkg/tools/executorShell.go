@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ThomasRooney/gexpect"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Time to wait for the prompt after the interrupt, before the shell process is terminated
+const shellInterruptGrace = 5 * time.Second
+
+// Time to wait for the prompt after the interrupt on the context cancellation
+const shellCancelGrace = time.Second
+
+var ErrShellTerminated = errors.New("shell session terminated")
+var errShellClosed = errors.New("shell closed")
+
+// shell is an interactive bash process driven through a pseudo terminal.
+// Every prompt printed by the shell is delivered by the single reader goroutine,
+// so the command execution can be abandoned on timeout or context cancellation.
+type shell struct {
+	process    *gexpect.ExpectSubprocess
+	prompt     string
+	exitMarker string
+	stderrFile string
+	sandboxed  bool
+
+	outputs chan shellOutput
+	closed  chan struct{}
+}
+
+type shellOutput struct {
+	data []byte
+	err  error
+}
+
+func startShell(ctx context.Context, dir string, env []string, sandbox CommandSandbox) (*shell, error) {
+	stderrFile, err := os.CreateTemp("", "libagent_command_executor_stderr_")
+	if err != nil {
+		return nil, err
+	}
+	stderrFile.Close()
+
+	sh := &shell{
+		// Create a random UUIDs to set as a prompt to be sure that there are command end,
+		// the exit marker precedes the last command exit status in the prompt
+		prompt:     uuid.New().String(),
+		exitMarker: uuid.New().String(),
+		stderrFile: stderrFile.Name(),
+		sandboxed:  sandbox.Enabled,
+		outputs:    make(chan shellOutput, 1),
+		closed:     make(chan struct{}),
+	}
+
+	sh.process, err = gexpect.Command("bash --norc --noprofile")
+	if err != nil {
+		os.Remove(sh.stderrFile)
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+	sh.process.Cmd.Dir = dir
+	sh.process.Cmd.Env = append(sandbox.env(env),
+		"PS1="+sh.exitMarker+"$?"+sh.prompt,
+		"PS2=",
+	)
+	if sandbox.Enabled {
+		if err := sandbox.apply(sh.process.Cmd, []string{dir, sh.stderrFile}); err != nil {
+			os.Remove(sh.stderrFile)
+			return nil, err
+		}
+	}
+	if err := sh.process.Start(); err != nil {
+		os.Remove(sh.stderrFile)
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+	go sh.readLoop()
+
+	// Expect the initial prompt, then send raw mode without echo,
+	// keeping the signals for the interrupt on timeout
+	for _, command := range []string{"", "stty raw -echo isig\n"} {
+		if command != "" {
+			if err := sh.process.Send(command); err != nil {
+				sh.close()
+				return nil, fmt.Errorf("shell setup: %w", err)
+			}
+		}
+		select {
+		case output := <-sh.outputs:
+			if output.err != nil {
+				sh.close()
+				return nil, fmt.Errorf("shell setup: %w", output.err)
+			}
+		case <-time.After(10 * time.Second):
+			sh.close()
+			return nil, fmt.Errorf("shell setup: prompt timeout")
+		case <-ctx.Done():
+			sh.close()
+			return nil, fmt.Errorf("shell setup: %w", ctx.Err())
+		}
+	}
+
+	return sh, nil
+}
+
+func (sh *shell) readLoop() {
+	for {
+		sh.process.Capture()
+		err := sh.process.Expect(sh.prompt)
+		output := shellOutput{
+			data: sh.process.Collect(),
+			err:  err,
+		}
+		select {
+		case sh.outputs <- output:
+		case <-sh.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run executes the command, interrupting it on timeout or context cancellation.
+// ErrShellTerminated is returned when the shell process exited or did not respond to the interrupt.
+func (sh *shell) run(ctx context.Context, command string, timeout time.Duration) (CommandResult, error) {
+	result := CommandResult{
+		Command:  command,
+		ExitCode: -1,
+	}
+
+	// Trim trailing '\' to avoid escaping last '\n' symbol,
+	// then group the command to redirect its stderr to the separate file,
+	// the shell state changes (cd, export, etc) are kept since the group is not a subshell
+	wrapped := fmt.Sprintf("{ %s\n} 2>%s\n",
+		strings.TrimSuffix(command, `\`),
+		shellQuote(sh.stderrFile),
+	)
+
+	started := time.Now()
+	if err := sh.process.Send(wrapped); err != nil {
+		return result, fmt.Errorf("send command: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var output shellOutput
+	var ctxErr error
+	select {
+	case output = <-sh.outputs:
+	case <-sh.closed:
+		output.err = errShellClosed
+	case <-timer.C:
+		result.TimedOut = true
+	case <-ctx.Done():
+		ctxErr = ctx.Err()
+	}
+
+	if result.TimedOut || ctxErr != nil {
+		log.Debug().Msgf("command executor interrupt: %s", command)
+		grace := shellInterruptGrace
+		if ctxErr != nil {
+			grace = shellCancelGrace
+		}
+		sh.process.Send(string([]byte{0x03}))
+		select {
+		case output = <-sh.outputs:
+		case <-sh.closed:
+			output.err = errShellClosed
+		case <-time.After(grace):
+			output.err = fmt.Errorf("no prompt after interrupt")
+		}
+	}
+	result.Duration = time.Since(started)
+
+	data := string(output.data)
+	if idx := strings.LastIndex(data, sh.exitMarker); idx != -1 {
+		exitCode, err := strconv.Atoi(strings.TrimSuffix(data[idx+len(sh.exitMarker):], sh.prompt))
+		if err == nil {
+			result.ExitCode = exitCode
+		}
+		data = data[:idx]
+	}
+	result.Stdout = strings.TrimSpace(data)
+
+	if output.err != errShellClosed {
+		stderr, err := os.ReadFile(sh.stderrFile)
+		if err != nil {
+			log.Warn().Err(err).Msg("read command stderr")
+		}
+		result.Stderr = strings.TrimSpace(string(stderr))
+	}
+
+	if output.err != nil {
+		return result, fmt.Errorf("%w: %w", ErrShellTerminated, output.err)
+	}
+	return result, ctxErr
+}
+
+// cwd returns the current working directory of the shell, or empty string if it is unknown.
+func (sh *shell) cwd() string {
+	if sh.process.Cmd.Process == nil {
+		return ""
+	}
+	dir, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(sh.process.Cmd.Process.Pid), "cwd"))
+	if err != nil {
+		return ""
+	}
+	if sh.sandboxed {
+		// The directory is resolved through the sandbox root mount point
+		dir = strings.TrimPrefix(dir, sandboxRoot())
+	}
+	return dir
+}
+
+func (sh *shell) close() error {
+	select {
+	case <-sh.closed:
+		return nil
+	default:
+		close(sh.closed)
+	}
+
+	if err := os.Remove(sh.stderrFile); err != nil {
+		log.Warn().Err(err).Msg("Removing stderr file")
+	}
+	if sh.process.Cmd.Process != nil {
+		killSession(sh.process.Cmd.Process.Pid)
+	}
+	err := sh.process.Close()
+	// Reap the process, the exit error of the killed shell is expected
+	sh.process.Wait()
+	return err
+}
+
+// killSession kills the processes of the session led by the shell, including its background jobs,
+// the shell itself is left to be closed by the caller.
+func killSession(sid int) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == sid {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name may contain spaces, the fields after it are: state, ppid, pgrp, session
+		idx := bytes.LastIndexByte(stat, ')')
+		if idx == -1 {
+			continue
+		}
+		fields := strings.Fields(string(stat[idx+1:]))
+		if len(fields) < 4 || fields[3] != strconv.Itoa(sid) {
+			continue
+		}
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
+
+// shellQuote quotes the string to be safely used as a single bash word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
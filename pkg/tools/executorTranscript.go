@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Terminal size written to the asciicast header
+const (
+	transcriptWidth  = 120
+	transcriptHeight = 40
+)
+
+const (
+	TranscriptEventCommand   = "command"
+	TranscriptEventJobStart  = "job_start"
+	TranscriptEventJobOutput = "job_output"
+	TranscriptEventJobInput  = "job_input"
+	TranscriptEventJobKill   = "job_kill"
+)
+
+// CommandTranscript points to the session transcript files:
+// the asciinema v2 recording and the JSON lines log with an entry per command.
+type CommandTranscript struct {
+	Session  string `json:"session"`
+	CastPath string `json:"cast_path"`
+	LogPath  string `json:"log_path"`
+}
+
+// TranscriptEntry is the JSON lines log entry, the command output is recorded before the truncation.
+type TranscriptEntry struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Input string    `json:"input,omitempty"`
+	CommandResult
+}
+
+// transcript records the session commands, safe for concurrent use by the session and its jobs.
+type transcript struct {
+	CommandTranscript
+
+	mu      sync.Mutex
+	started time.Time
+	cast    *os.File
+	log     *os.File
+}
+
+func newTranscript(dir, session string) (*transcript, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	base := filepath.Join(dir, started.Format("20060102T150405.000")+"_"+session)
+
+	t := &transcript{
+		CommandTranscript: CommandTranscript{
+			Session:  session,
+			CastPath: base + ".cast",
+			LogPath:  base + ".jsonl",
+		},
+		started: started,
+	}
+
+	var err error
+	t.cast, err = os.OpenFile(t.CastPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+	t.log, err = os.OpenFile(t.LogPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		t.cast.Close()
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+
+	header, err := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     transcriptWidth,
+		"height":    transcriptHeight,
+		"timestamp": started.Unix(),
+		"title":     "libagent command executor session " + session,
+		"env": map[string]string{
+			"SHELL": "/bin/bash",
+			"TERM":  "xterm-256color",
+		},
+	})
+	if err == nil {
+		_, err = fmt.Fprintf(t.cast, "%s\n", header)
+	}
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("write transcript header: %w", err)
+	}
+	return t, nil
+}
+
+// commandStarted writes the command prompt to the recording when the command is sent to the shell,
+// keeping the event times in order with the job events recorded while it runs.
+func (t *transcript) commandStarted(command string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cast == nil {
+		return errors.New("transcript closed")
+	}
+	return t.castEvent(time.Since(t.started), "o", "$ "+command+"\n")
+}
+
+// record writes the event to both files, the recording gets it at the current time.
+func (t *transcript) record(event, input string, result CommandResult) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cast == nil {
+		return errors.New("transcript closed")
+	}
+
+	now := time.Now()
+	entry, err := json.Marshal(TranscriptEntry{
+		Time:          now,
+		Event:         event,
+		Input:         input,
+		CommandResult: result,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(t.log, "%s\n", entry); err != nil {
+		return err
+	}
+
+	end := now.Sub(t.started)
+	switch event {
+	case TranscriptEventCommand:
+		// The command itself is written by commandStarted
+		err = t.castOutput(end, result)
+		if err == nil && result.ExitCode != 0 {
+			err = t.castEvent(end, "o", fmt.Sprintf("[exit code %d]\n", result.ExitCode))
+		}
+	case TranscriptEventJobStart:
+		err = t.castEvent(end, "o", fmt.Sprintf("$ %s &\n[%s started]\n", result.Command, result.JobID))
+	case TranscriptEventJobOutput:
+		err = t.castOutput(end, result)
+		if err == nil && !result.Running {
+			err = t.castEvent(end, "o", fmt.Sprintf("[%s finished, exit code %d]\n", result.JobID, result.ExitCode))
+		}
+	case TranscriptEventJobInput:
+		err = t.castEvent(end, "i", input)
+		if err == nil {
+			err = t.castOutput(end, result)
+		}
+	case TranscriptEventJobKill:
+		err = t.castOutput(end, result)
+		if err == nil {
+			err = t.castEvent(end, "o", fmt.Sprintf("[%s killed]\n", result.JobID))
+		}
+	}
+	return err
+}
+
+func (t *transcript) castOutput(at time.Duration, result CommandResult) error {
+	for _, output := range []string{result.Stdout, result.Stderr} {
+		if output == "" {
+			continue
+		}
+		if err := t.castEvent(at, "o", strings.TrimSuffix(output, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// castEvent writes the asciicast event, new lines are converted for the terminal playback.
+func (t *transcript) castEvent(at time.Duration, eventType, data string) error {
+	if eventType == "o" {
+		data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\n", "\r\n")
+	}
+	event, err := json.Marshal([]any{at.Seconds(), eventType, data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(t.cast, "%s\n", event)
+	return err
+}
+
+func (t *transcript) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cast == nil {
+		return nil
+	}
+	err := errors.Join(t.cast.Close(), t.log.Close())
+	t.cast, t.log = nil, nil
+	return err
+}
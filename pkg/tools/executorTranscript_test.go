@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTranscriptMonotonicTimes(t *testing.T) {
+	tr, err := newTranscript(t.TempDir(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job output is recorded while the long command runs
+	if err := tr.commandStarted("sleep 1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	tr.record(TranscriptEventJobOutput, "", CommandResult{JobID: "job-1", Stdout: "tick", Running: true})
+	time.Sleep(20 * time.Millisecond)
+	tr.record(TranscriptEventCommand, "", CommandResult{Command: "sleep 1", Stdout: "done", Duration: time.Second})
+	if err := tr.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tr.CastPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+
+	outputs := []string{}
+	last := 0.0
+	for scanner.Scan() {
+		event := []any{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		at := event[0].(float64)
+		if at < last {
+			t.Errorf("event %q at %f is before the previous one at %f", event[2], at, last)
+		}
+		last = at
+		outputs = append(outputs, event[2].(string))
+	}
+
+	want := []string{"$ sleep 1\r\n", "tick\r\n", "done\r\n"}
+	if len(outputs) != len(want) {
+		t.Fatalf("events %q, want %q", outputs, want)
+	}
+	for i := range want {
+		if outputs[i] != want[i] {
+			t.Errorf("event %d %q, want %q", i, outputs[i], want[i])
+		}
+	}
+}
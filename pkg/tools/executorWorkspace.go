@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Maximum number of files returned by the artifacts listing
+const maxArtifacts = 1000
+
+// CommandWorkspace configures the working directory of the shell sessions.
+// By default every session gets its own temporary directory, removed when the session is closed.
+type CommandWorkspace struct {
+	// Dir is the directory shared by all the sessions instead of the temporary ones.
+	// It is created if missing and then removed on cleanup, an existing directory is used as is and never removed.
+	Dir string
+	// Keep prevents the workspace removal on the session close and cleanup
+	Keep bool
+	// Seed files are copied into the new workspace by their base names, directories are copied with their contents
+	Seed []string
+	// GitRepository is cloned into the new workspace, with GitRef checked out if set.
+	// The seed files and the clone are put only into the directory created by the tool.
+	GitRepository string
+	GitRef        string
+}
+
+// Artifact is the regular file found in the session workspace.
+type Artifact struct {
+	// Path is relative to the workspace directory
+	Path     string    `json:"path"`
+	HostPath string    `json:"host_path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Artifacts lists the files in the working directory of the named session, creating it if needed.
+// The bool result reports if the listing was truncated.
+func (s *CommandExecutorTool) Artifacts(session string) ([]Artifact, bool, error) {
+	cs, err := s.session(context.Background(), session)
+	if err != nil {
+		return nil, false, err
+	}
+	return listArtifacts(cs.dir)
+}
+
+// sessionDir returns the working directory for the new session, preparing it if needed,
+// and whether it should be removed on the session close.
+func (s *CommandExecutorTool) sessionDir(ctx context.Context, name string) (string, bool, error) {
+	if s.Workspace.shared() {
+		dir, err := filepath.Abs(s.Workspace.Dir)
+		if err != nil {
+			return "", false, err
+		}
+
+		s.workspaceMu.Lock()
+		defer s.workspaceMu.Unlock()
+		if s.workspaceReady {
+			return dir, false, nil
+		}
+		created, err := createDir(dir)
+		if err != nil {
+			return "", false, err
+		}
+		if !created {
+			log.Debug().Msgf("command executor workspace %s exists, used as is", dir)
+			s.workspaceReady = true
+			return dir, false, nil
+		}
+		if err := s.Workspace.prepare(ctx, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", false, err
+		}
+		s.workspaceReady = true
+		s.workspaceCreated = true
+		log.Debug().Msgf("command executor workspace %s prepared", dir)
+		return dir, false, nil
+	}
+
+	dir, err := os.MkdirTemp("", "libagent_command_executor_session_"+name+"_")
+	if err != nil {
+		return "", false, err
+	}
+	if err := s.Workspace.prepare(ctx, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", false, err
+	}
+	log.Debug().Msgf("command executor session %s temp directory %s created", name, dir)
+	return dir, !s.Workspace.Keep, nil
+}
+
+// removeWorkspace removes the shared workspace directory if it was created by the tool and is not kept.
+// Called with workspaceMu held after all the sessions are closed.
+func (s *CommandExecutorTool) removeWorkspace() error {
+	created := s.workspaceCreated
+	s.workspaceReady = false
+	s.workspaceCreated = false
+	if !created || s.Workspace.Keep {
+		return nil
+	}
+	log.Debug().Msgf("command executor remove workspace directory %s", s.Workspace.Dir)
+	return os.RemoveAll(s.Workspace.Dir)
+}
+
+// shared reports whether all the sessions work in the same configured directory.
+func (w CommandWorkspace) shared() bool {
+	return w.Dir != ""
+}
+
+// prepare clones the git repository and copies the seed files into the new empty workspace directory.
+func (w CommandWorkspace) prepare(ctx context.Context, dir string) error {
+	if w.GitRepository != "" {
+		if strings.HasPrefix(w.GitRef, "-") {
+			return fmt.Errorf("invalid git ref %q", w.GitRef)
+		}
+		if err := runGit(ctx, "", "clone", "--quiet", "--", w.GitRepository, dir); err != nil {
+			return err
+		}
+		if w.GitRef != "" {
+			if err := runGit(ctx, dir, "checkout", "--quiet", w.GitRef); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, seed := range w.Seed {
+		info, err := os.Stat(seed)
+		if err != nil {
+			return fmt.Errorf("workspace seed: %w", err)
+		}
+		if info.IsDir() {
+			err = copyDir(seed, dir)
+		} else {
+			err = copyFile(seed, filepath.Join(dir, filepath.Base(seed)), info.Mode())
+		}
+		if err != nil {
+			return fmt.Errorf("workspace seed %s: %w", seed, err)
+		}
+	}
+	return nil
+}
+
+// createDir creates the directory with its parents, reporting whether it did not exist.
+func createDir(dir string) (bool, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	return true, os.MkdirAll(dir, 0o755)
+}
+
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w - output: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// listArtifacts returns the regular files in the directory sorted by path, skipping the .git directory.
+func listArtifacts(dir string) ([]Artifact, bool, error) {
+	artifacts := []Artifact{}
+	truncated := false
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(artifacts) == maxArtifacts {
+			truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, Artifact{
+			Path:     rel,
+			HostPath: path,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return nil
+	})
+	slices.SortFunc(artifacts, func(a, b Artifact) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return artifacts, truncated, err
+}
+
+func renderArtifacts(artifacts []Artifact, truncated bool) string {
+	if len(artifacts) == 0 {
+		return "no files in the workspace"
+	}
+	sb := strings.Builder{}
+	for _, artifact := range artifacts {
+		fmt.Fprintf(&sb, "- %s (%d bytes, modified %s)\n",
+			artifact.Path, artifact.Size, artifact.Modified.Format(time.RFC3339),
+		)
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "(listing truncated to %d files)\n", maxArtifacts)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// runIn runs the command in the session, failing the test on a non-zero exit code.
+func runIn(t *testing.T, executor *CommandExecutorTool, session, command string) string {
+	t.Helper()
+	result, err := executor.RunCommandContext(context.Background(), session, command, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("command %q exit code %d, stderr: %s", command, result.ExitCode, result.Stderr)
+	}
+	return result.Stdout
+}
+
+func TestWorkspacePersistence(t *testing.T) {
+	seed := filepath.Join(t.TempDir(), "seed.txt")
+	if err := os.WriteFile(seed, []byte("seed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "workspace")
+	workspace := CommandWorkspace{Dir: dir, Keep: true, Seed: []string{seed}}
+
+	executor := &CommandExecutorTool{Workspace: workspace}
+	runIn(t, executor, "build", "echo built > out.txt; echo changed > seed.txt")
+	if got := runIn(t, executor, "test", "cat out.txt seed.txt"); got != "built\nchanged" {
+		t.Errorf("output %q, want the files of the other session", got)
+	}
+	if err := executor.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The kept workspace is reused as is by the next executor, not seeded again
+	executor = &CommandExecutorTool{Workspace: workspace}
+	if got := runIn(t, executor, "", "cat out.txt seed.txt"); got != "built\nchanged" {
+		t.Errorf("output %q, want the files of the previous executor", got)
+	}
+	artifacts, _, err := executor.Artifacts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 2 || artifacts[0].Path != "out.txt" || artifacts[1].HostPath != filepath.Join(dir, "seed.txt") {
+		t.Errorf("artifacts %+v, want the workspace files", artifacts)
+	}
+	if err := executor.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.txt")); err != nil {
+		t.Errorf("existing workspace is not kept: %v", err)
+	}
+}
+
+func TestWorkspaceCleanup(t *testing.T) {
+	t.Run("created", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "workspace")
+		executor := &CommandExecutorTool{Workspace: CommandWorkspace{Dir: dir}}
+		runIn(t, executor, "", "touch file")
+		if err := executor.Cleanup(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("created workspace is not removed: %v", err)
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("user"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		seed := filepath.Join(t.TempDir(), "main.go")
+		if err := os.WriteFile(seed, []byte("seed"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// The clone of the missing repository would fail if attempted
+		executor := &CommandExecutorTool{Workspace: CommandWorkspace{
+			Dir:           dir,
+			Seed:          []string{seed},
+			GitRepository: filepath.Join(t.TempDir(), "missing"),
+		}}
+		if got := runIn(t, executor, "", "cat main.go"); got != "user" {
+			t.Errorf("main.go %q, want the user file", got)
+		}
+		if err := executor.Cleanup(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "main.go")); err != nil {
+			t.Errorf("existing workspace is removed: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		keep bool
+	}{
+		{"session", false},
+		{"kept session", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &CommandExecutorTool{Workspace: CommandWorkspace{Keep: tt.keep}}
+			closed := runIn(t, executor, "closed", "pwd")
+			open := runIn(t, executor, "open", "pwd")
+			defer os.RemoveAll(closed)
+			defer os.RemoveAll(open)
+
+			if err := executor.CloseSession("closed"); err != nil {
+				t.Fatal(err)
+			}
+			if err := executor.Cleanup(); err != nil {
+				t.Fatal(err)
+			}
+			for _, dir := range []string{closed, open} {
+				_, err := os.Stat(dir)
+				if kept := err == nil; kept != tt.keep {
+					t.Errorf("session directory %s kept %t, want %t", dir, kept, tt.keep)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkspaceGitClone(t *testing.T) {
+	repository := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repository
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v, output: %s", args, err, output)
+		}
+	}
+	git("init", "--quiet")
+	if err := os.WriteFile(filepath.Join(repository, "version"), []byte("1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "version")
+	git("commit", "--quiet", "-m", "first")
+	git("tag", "v1")
+	if err := os.WriteFile(filepath.Join(repository, "version"), []byte("2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "--quiet", "-am", "second")
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"head", "", "2"},
+		{"ref", "v1", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &CommandExecutorTool{Workspace: CommandWorkspace{
+				Dir:           filepath.Join(t.TempDir(), "workspace"),
+				GitRepository: repository,
+				GitRef:        tt.ref,
+			}}
+			defer executor.Cleanup()
+			if got := runIn(t, executor, "", "cat version"); got != tt.want {
+				t.Errorf("version %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommandResult(t *testing.T) {
+	executor := &CommandExecutorTool{StreamLimit: 64}
+	defer executor.Cleanup()
+
+	tests := []struct {
+		name     string
+		command  string
+		exitCode int
+		stdout   string
+		stderr   string
+		// truncated output is checked for the omission marker instead of stdout
+		truncated   bool
+		minDuration time.Duration
+	}{
+		{"success", "echo out", 0, "out", "", false, 0},
+		{"failure", "false", 1, "", "", false, 0},
+		{"exit status", "(exit 42)", 42, "", "", false, 0},
+		{"pipeline status", "echo out | grep -q missing", 1, "", "", false, 0},
+		{"separate stderr", "echo out; echo err >&2; echo err2 1>&2; (exit 2)", 2, "out", "err\nerr2", false, 0},
+		{"duration", "sleep 0.2", 0, "", "", false, 200 * time.Millisecond},
+		{"truncated", "head -c 1000 /dev/zero | tr '\\0' x", 0, "", "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := executor.RunCommand(tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.ExitCode != tt.exitCode {
+				t.Errorf("exit code %d, want %d", result.ExitCode, tt.exitCode)
+			}
+			if result.Stderr != tt.stderr {
+				t.Errorf("stderr %q, want %q", result.Stderr, tt.stderr)
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("truncated %t, want %t", result.Truncated, tt.truncated)
+			}
+			if tt.truncated {
+				if len(result.Stdout) >= 1000 || !strings.Contains(result.Stdout, "bytes omitted") {
+					t.Errorf("stdout %q, want the omission marker", result.Stdout)
+				}
+			} else if result.Stdout != tt.stdout {
+				t.Errorf("stdout %q, want %q", result.Stdout, tt.stdout)
+			}
+			if result.Duration < tt.minDuration || result.Duration > tt.minDuration+5*time.Second {
+				t.Errorf("duration %s, want at least %s", result.Duration, tt.minDuration)
+			}
+			if result.TimedOut {
+				t.Errorf("command timed out")
+			}
+		})
+	}
+
+	// The shell state is kept between the commands
+	if _, err := executor.RunCommand("cd /; export X=1"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := executor.RunCommand("echo $PWD $X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "/ 1" {
+		t.Errorf("stdout %q, want the state of the previous command", result.Stdout)
+	}
+}
+
+func TestRunCommandInterrupt(t *testing.T) {
+	executor := &CommandExecutorTool{Timeout: time.Minute}
+	defer executor.Cleanup()
+
+	t.Run("timeout", func(t *testing.T) {
+		output, err := executor.Call(context.Background(), `{"command": "echo started; sleep 60", "timeout": 1}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(output, "timed_out: true") || !strings.Contains(output, "started") {
+			t.Errorf("output %q, want the timed out command with its output", output)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		result, err := executor.RunCommandContext(ctx, "", "sleep 60", 0)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error %v, want the context error", err)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond+shellCancelGrace {
+			t.Errorf("returned after %s, want within the cancel grace %s", elapsed, shellCancelGrace)
+		}
+		if result.TimedOut {
+			t.Errorf("cancelled command reported as timed out")
+		}
+	})
+
+	// The interrupted commands leave the session usable
+	result, err := executor.RunCommand("echo ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "ok" {
+		t.Errorf("result %+v after the interrupts, want the command output", result)
+	}
+}
+
+func TestCommandResultString(t *testing.T) {
+	tests := []struct {
+		name   string
+		result CommandResult
+		want   string
+	}{
+		{
+			"command",
+			CommandResult{Session: DefaultCommandSession, Stdout: "out\n", Stderr: "err", ExitCode: 1, Duration: 1500 * time.Microsecond},
+			"exit_code: 1\nduration: 2ms\nstdout:\nout\nstderr:\nerr",
+		},
+		{
+			"truncated timeout",
+			CommandResult{Session: "build", ExitCode: 130, Duration: time.Second, TimedOut: true, Truncated: true, Stdout: "head ... tail"},
+			"session: build\nexit_code: 130\nduration: 1s\ntimed_out: true\ntruncated: true\nstdout:\nhead ... tail",
+		},
+		{
+			"running job",
+			CommandResult{JobID: "job-1", Running: true, ExitCode: -1, Duration: time.Second},
+			"job_id: job-1\nrunning: true\nduration: 1s\n(no output)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.String(); got != tt.want {
+				t.Errorf("result %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,710 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/config"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultFileReadLimit is the maximum size in bytes of the file content returned to the model.
+const DefaultFileReadLimit = 64 * 1024
+
+const (
+	maxListEntries    = 1000
+	maxGrepResults    = 200
+	maxGrepLineLength = 300
+	// Size of the file head checked for NUL bytes to detect binary files
+	binarySniffSize = 8000
+)
+
+var ReadFileDefinition = llms.FunctionDefinition{
+	Name: "readFile",
+	Description: `Reads a text file from the workspace and returns its lines prefixed with line numbers.
+Use start_line and end_line to read a part of a large file.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "the file path relative to the workspace root",
+			},
+			"start_line": map[string]any{
+				"type":        "integer",
+				"description": "optional first line to read, starting from 1",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "optional last line to read, inclusive",
+			},
+		},
+	},
+}
+
+var WriteFileDefinition = llms.FunctionDefinition{
+	Name: "writeFile",
+	Description: `Writes the content to a file in the workspace as is, no escaping is needed.
+Creates the file and its parent directories if missing and overwrites the existing file unless append is set.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "the file path relative to the workspace root",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "the full file content",
+			},
+			"append": map[string]any{
+				"type":        "boolean",
+				"description": "append the content to the end of the file instead of overwriting it",
+			},
+		},
+	},
+}
+
+var ListDirDefinition = llms.FunctionDefinition{
+	Name:        "listDir",
+	Description: `Lists the workspace directory entries, directories are suffixed with a slash.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "the directory path relative to the workspace root, the root by default",
+			},
+			"recursive": map[string]any{
+				"type":        "boolean",
+				"description": "list the nested directories as well, skipping .git",
+			},
+		},
+	},
+}
+
+var GrepDefinition = llms.FunctionDefinition{
+	Name: "grep",
+	Description: `Searches the workspace text files for the lines matching the pattern.
+Returns the matches as path:line: text.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "the text to search for, a Go regular expression if regex is set",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "optional file or directory to search in, the workspace root by default",
+			},
+			"regex": map[string]any{
+				"type":        "boolean",
+				"description": "treat the pattern as a regular expression",
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "case insensitive search",
+			},
+			"include": map[string]any{
+				"type":        "string",
+				"description": "optional file name glob to search in, for example *.go",
+			},
+		},
+	},
+}
+
+type ReadFileArgs struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+type WriteFileArgs struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Append  bool   `json:"append,omitempty"`
+}
+
+type ListDirArgs struct {
+	Path      string `json:"path,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+type GrepArgs struct {
+	Pattern    string `json:"pattern"`
+	Path       string `json:"path,omitempty"`
+	Regex      bool   `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	Include    string `json:"include,omitempty"`
+}
+
+// GrepMatch is the line matching the grep pattern.
+type GrepMatch struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// FileTools implements the file tools confined to the workspace root directory.
+// Paths are resolved relative to the root, absolute paths and symlinks leading outside of it are rejected.
+type FileTools struct {
+	Root      string
+	ReadLimit int
+	// Keep prevents the removal of the root directory created by the tools on cleanup
+	Keep bool
+
+	mu      sync.Mutex
+	ready   bool
+	created bool
+}
+
+// ReadFile returns the file lines from start to end inclusive, numbered from 1.
+// Zero end means the end of the file. The output is cut to ReadLimit bytes.
+func (t *FileTools) ReadFile(path string, start, end int) (string, error) {
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+
+	lines := splitLines(string(data))
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("%s is empty", path), nil
+	}
+	if start > end {
+		return "", fmt.Errorf("invalid line range %d-%d, the file has %d lines", start, end, len(lines))
+	}
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%s (lines %d-%d of %d)\n", t.rel(abs), start, end, len(lines))
+	for i := start; i <= end; i++ {
+		line := fmt.Sprintf("%6d\t%s\n", i, strings.TrimSuffix(lines[i-1], "\n"))
+		if t.ReadLimit > 0 && sb.Len()+len(line) > t.ReadLimit {
+			fmt.Fprintf(&sb, "(output truncated, continue from start_line %d)\n", i)
+			break
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// WriteFile writes or appends the content to the file, creating its parent directories.
+func (t *FileTools) WriteFile(path, content string, append bool) (string, error) {
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return "", err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	if err := writeFileNoFollow(abs, []byte(content), flags, 0o644); err != nil {
+		return "", err
+	}
+
+	log.Debug().Msgf("file tools: wrote %d bytes to %s", len(content), abs)
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), t.rel(abs)), nil
+}
+
+// ListDir returns the directory entries relative to the root, directories are suffixed with a slash.
+// The bool result reports if the listing was truncated.
+func (t *FileTools) ListDir(path string, recursive bool) ([]string, bool, error) {
+	abs, err := t.resolve(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries := []string{}
+	truncated := false
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == abs {
+			if !d.IsDir() {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			return nil
+		}
+		if len(entries) == maxListEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		entry := t.rel(p)
+		if d.IsDir() {
+			entries = append(entries, entry+"/")
+			if !recursive || d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, truncated, err
+}
+
+// Grep searches the text files under the path for the lines matching the pattern.
+// The bool result reports if the results were truncated.
+func (t *FileTools) Grep(args GrepArgs) ([]GrepMatch, bool, error) {
+	if args.Pattern == "" {
+		return nil, false, errors.New("empty pattern")
+	}
+	pattern := args.Pattern
+	if !args.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if args.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if args.Include != "" {
+		if _, err := filepath.Match(args.Include, ""); err != nil {
+			return nil, false, fmt.Errorf("invalid include glob: %w", err)
+		}
+	}
+
+	abs, err := t.resolve(args.Path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	matches := []GrepMatch{}
+	truncated := false
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if args.Include != "" {
+			if ok, _ := filepath.Match(args.Include, d.Name()); !ok {
+				return nil
+			}
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			log.Warn().Err(err).Msgf("file tools: grep read %s", p)
+			return nil
+		}
+		if isBinary(data) {
+			return nil
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			if !re.MatchString(text) {
+				continue
+			}
+			if len(matches) == maxGrepResults {
+				truncated = true
+				return filepath.SkipAll
+			}
+			if len(text) > maxGrepLineLength {
+				text = strings.ToValidUTF8(text[:maxGrepLineLength], "") + "..."
+			}
+			matches = append(matches, GrepMatch{
+				Path: t.rel(p),
+				Line: line,
+				Text: text,
+			})
+		}
+		return nil
+	})
+	return matches, truncated, err
+}
+
+// resolve returns the absolute path inside the root for the relative or absolute path, its symlinks resolved.
+// The path is rejected if it or any symlink on the way leads outside of the root, the dangling ones included.
+func (t *FileTools) resolve(path string) (string, error) {
+	root, err := t.root()
+	if err != nil {
+		return "", err
+	}
+	return resolveInRoot(root, path)
+}
+
+// resolveInRoot resolves the path inside the absolute root directory the same way as FileTools.resolve.
+func resolveInRoot(root, path string) (string, error) {
+	abs := filepath.Clean(path)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(root, abs)
+	}
+	if !within(root, abs) {
+		return "", fmt.Errorf("path %s is outside of the workspace", path)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+	real, err := resolveWithin(realRoot, rel)
+	if err != nil {
+		return "", fmt.Errorf("path %s: %w", path, err)
+	}
+	// Keep the root as configured for the output paths
+	rel, err = filepath.Rel(realRoot, real)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// resolveWithin resolves the relative path inside the root component by component, following the symlinks
+// even if their targets do not exist yet. It fails if any step leads outside of the root.
+func resolveWithin(root, rel string) (string, error) {
+	const maxLinks = 40
+
+	current := root
+	pending := strings.Split(rel, string(filepath.Separator))
+	links := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if name == "" || name == "." {
+			continue
+		}
+
+		next := filepath.Join(current, name)
+		if !within(root, next) {
+			return "", errors.New("outside of the workspace")
+		}
+		info, err := os.Lstat(next)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && info.Mode()&fs.ModeSymlink == 0) {
+			current = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		links++
+		if links > maxLinks {
+			return "", errors.New("too many levels of symbolic links")
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			target = filepath.Clean(target)
+			if !within(root, target) {
+				return "", errors.New("symlink leads outside of the workspace")
+			}
+			current = root
+			target, _ = filepath.Rel(root, target)
+		}
+		pending = append(strings.Split(target, string(filepath.Separator)), pending...)
+	}
+	return current, nil
+}
+
+// writeFileNoFollow writes the resolved file, failing instead of following the symlink created after the resolve.
+func writeFileNoFollow(path string, data []byte, flags int, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, flags|syscall.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// root returns the absolute root directory, creating it on the first use.
+func (t *FileTools) root() (string, error) {
+	if t.Root == "" {
+		return "", errors.New("file tools root is not configured")
+	}
+	root, err := filepath.Abs(t.Root)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.ready {
+		created, err := createDir(root)
+		if err != nil {
+			return "", err
+		}
+		t.ready = true
+		t.created = created
+	}
+	return root, nil
+}
+
+// rel returns the path relative to the root for the output.
+func (t *FileTools) rel(abs string) string {
+	root, err := filepath.Abs(t.Root)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
+
+// Cleanup removes the root directory if it was created by the tools and is not kept.
+func (t *FileTools) Cleanup() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	created := t.created
+	t.ready = false
+	t.created = false
+	if !created || t.Keep {
+		return nil
+	}
+	log.Debug().Msgf("file tools: remove workspace directory %s", t.Root)
+	return os.RemoveAll(t.Root)
+}
+
+func (t *FileTools) callReadFile(ctx context.Context, input string) (string, error) {
+	args := ReadFileArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	return t.ReadFile(args.Path, args.StartLine, args.EndLine)
+}
+
+func (t *FileTools) callWriteFile(ctx context.Context, input string) (string, error) {
+	args := WriteFileArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	return t.WriteFile(args.Path, args.Content, args.Append)
+}
+
+func (t *FileTools) callListDir(ctx context.Context, input string) (string, error) {
+	args := ListDirArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	entries, truncated, err := t.ListDir(args.Path, args.Recursive)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "the directory is empty", nil
+	}
+	if truncated {
+		entries = append(entries, fmt.Sprintf("(listing truncated to %d entries)", maxListEntries))
+	}
+	return strings.Join(entries, "\n"), nil
+}
+
+func (t *FileTools) callGrep(ctx context.Context, input string) (string, error) {
+	args := GrepArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	matches, truncated, err := t.Grep(args)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "no matches found", nil
+	}
+
+	sb := strings.Builder{}
+	for _, match := range matches {
+		fmt.Fprintf(&sb, "%s:%d: %s\n", match.Path, match.Line, match.Text)
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "(results truncated to %d matches)\n", maxGrepResults)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// within reports whether the path is the root or inside of it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) != -1
+}
+
+// splitLines splits the text into lines keeping the line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// fileToolsDefinitions are the tools sharing the FileTools of the executor.
+var fileToolsDefinitions = []llms.FunctionDefinition{
+	ReadFileDefinition,
+	WriteFileDefinition,
+	ListDirDefinition,
+	GrepDefinition,
+	ApplyEditDefinition,
+}
+
+// shareWorkspace points the command executor and the file tools to the same workspace directory
+// when both are enabled. With none configured, a new temporary directory is created and prepared for both,
+// then removed on the executor cleanup unless kept. The file tools alone get their own temporary root,
+// created on the first use, and the command executor alone keeps the per-session directories.
+func shareWorkspace(ctx context.Context, cfg *config.Config, enabled func(name string) bool) error {
+	fileToolsEnabled := !cfg.FileToolsDisable && slices.ContainsFunc(fileToolsDefinitions,
+		func(definition llms.FunctionDefinition) bool {
+			return enabled(definition.Name)
+		},
+	)
+	if !fileToolsEnabled {
+		return nil
+	}
+	commandExecutorEnabled := !cfg.CommandExecutorDisable && enabled(CommandExecutorDefinition.Name)
+
+	switch {
+	case cfg.FileToolsRoot != "":
+		if commandExecutorEnabled && cfg.CommandExecutorWorkspaceDir == "" {
+			cfg.CommandExecutorWorkspaceDir = cfg.FileToolsRoot
+		}
+	case commandExecutorEnabled && cfg.CommandExecutorWorkspaceDir != "":
+		cfg.FileToolsRoot = cfg.CommandExecutorWorkspaceDir
+	case commandExecutorEnabled:
+		dir, err := sharedResource(ctx, "workspace", func() (string, func() error, error) {
+			return createWorkspace(ctx, *cfg)
+		})
+		if err != nil {
+			return fmt.Errorf("shared workspace: %w", err)
+		}
+		cfg.CommandExecutorWorkspaceDir = dir
+		cfg.FileToolsRoot = dir
+	default:
+		cfg.FileToolsRoot = filepath.Join(os.TempDir(), "libagent_workspace_"+uuid.NewString())
+	}
+	return nil
+}
+
+// createWorkspace creates and prepares the temporary workspace directory shared by the command executor
+// and the file tools, the returned cleanup removes it unless kept.
+func createWorkspace(ctx context.Context, cfg config.Config) (string, func() error, error) {
+	workspace := CommandWorkspace{
+		Keep:          cfg.CommandExecutorWorkspaceKeep,
+		Seed:          cfg.CommandExecutorWorkspaceSeed,
+		GitRepository: cfg.CommandExecutorWorkspaceGitRepository,
+		GitRef:        cfg.CommandExecutorWorkspaceGitRef,
+	}
+	dir, err := os.MkdirTemp("", "libagent_workspace_")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := workspace.prepare(ctx, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	log.Debug().Msgf("shared workspace directory %s created", dir)
+
+	return dir, func() error {
+		if workspace.Keep {
+			log.Debug().Msgf("shared workspace directory %s kept", dir)
+			return nil
+		}
+		log.Debug().Msgf("remove shared workspace directory %s", dir)
+		return os.RemoveAll(dir)
+	}, nil
+}
+
+// sharedFileTools returns the FileTools of the executor, shared by all the file tools.
+func sharedFileTools(ctx context.Context, cfg config.Config) (*FileTools, error) {
+	return sharedResource(ctx, "fileTools", func() (*FileTools, func() error, error) {
+		fileTools := &FileTools{
+			Root:      cfg.FileToolsRoot,
+			ReadLimit: DefaultFileReadLimit,
+			Keep:      cfg.CommandExecutorWorkspaceKeep,
+		}
+		return fileTools, fileTools.Cleanup, nil
+	})
+}
+
+func init() {
+	for _, definition := range fileToolsDefinitions {
+		globalToolsRegistry = append(globalToolsRegistry,
+			func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+				if cfg.FileToolsDisable {
+					return nil, nil
+				}
+				fileTools, err := sharedFileTools(ctx, cfg)
+				if err != nil {
+					return nil, err
+				}
+
+				call := map[string]func(context.Context, string) (string, error){
+					ReadFileDefinition.Name:  fileTools.callReadFile,
+					WriteFileDefinition.Name: fileTools.callWriteFile,
+					ListDirDefinition.Name:   fileTools.callListDir,
+					GrepDefinition.Name:      fileTools.callGrep,
+					ApplyEditDefinition.Name: fileTools.callApplyEdit,
+				}[definition.Name]
+
+				return &tools.ToolData{
+					Definition: definition,
+					Call:       call,
+					Tool:       fileTools,
+				}, nil
+			},
+		)
+	}
+}
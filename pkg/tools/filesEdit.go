@@ -0,0 +1,520 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	searchBlockStart  = "<<<<<<< SEARCH"
+	searchBlockDivide = "======="
+	searchBlockEnd    = ">>>>>>> REPLACE"
+)
+
+var ApplyEditDefinition = llms.FunctionDefinition{
+	Name: "applyEdit",
+	Description: `Edits the workspace files. Use one of:
+- path, search and replace: replaces the exact search text, which must occur in the file exactly once
+- path and blocks: applies one or more blocks in the format
+` + searchBlockStart + `
+exact lines to find
+` + searchBlockDivide + `
+lines to replace them with
+` + searchBlockEnd + `
+- patch: applies a unified diff, which may create, change or delete multiple files
+The edit is applied only if all its parts match.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "the file path relative to the workspace root for the search and replace edits",
+			},
+			"search": map[string]any{
+				"type":        "string",
+				"description": "the exact text to find, including whitespace and indentation",
+			},
+			"replace": map[string]any{
+				"type":        "string",
+				"description": "the text to replace the search text with",
+			},
+			"blocks": map[string]any{
+				"type":        "string",
+				"description": "search and replace blocks applied in order",
+			},
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "the unified diff with paths relative to the workspace root",
+			},
+		},
+	},
+}
+
+type ApplyEditArgs struct {
+	Path    string `json:"path,omitempty"`
+	Search  string `json:"search,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	Blocks  string `json:"blocks,omitempty"`
+	Patch   string `json:"patch,omitempty"`
+}
+
+// SearchReplace is the exact text replacement.
+type SearchReplace struct {
+	Search  string
+	Replace string
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the unified diff part for a single file, empty path means /dev/null.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []hunk
+}
+
+type hunk struct {
+	oldStart int
+	lines    []hunkLine
+	// oldNoEOL and newNoEOL are set by the "\ No newline at end of file" markers
+	oldNoEOL bool
+	newNoEOL bool
+}
+
+type hunkLine struct {
+	op   byte
+	text string
+}
+
+// ApplySearchReplace applies the replacements to the file in order.
+// Every search text must occur exactly once, otherwise the file is left unchanged.
+func (t *FileTools) ApplySearchReplace(path string, edits []SearchReplace) (string, error) {
+	if len(edits) == 0 {
+		return "", errors.New("no edits provided")
+	}
+	abs, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+
+	content := string(data)
+	for idx, edit := range edits {
+		if edit.Search == "" {
+			return "", fmt.Errorf("edit %d: empty search text", idx+1)
+		}
+		switch count := strings.Count(content, edit.Search); count {
+		case 1:
+			content = strings.Replace(content, edit.Search, edit.Replace, 1)
+		case 0:
+			return "", fmt.Errorf("edit %d: search text not found in %s, read the file to get the exact text", idx+1, path)
+		default:
+			return "", fmt.Errorf("edit %d: search text found %d times in %s, include more surrounding lines to make it unique", idx+1, count, path)
+		}
+	}
+
+	if err := writeFileNoFollow(abs, []byte(content), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(abs)); err != nil {
+		return "", err
+	}
+	log.Debug().Msgf("file tools: applied %d edits to %s", len(edits), abs)
+	return fmt.Sprintf("applied %d edits to %s", len(edits), t.rel(abs)), nil
+}
+
+// ApplyPatch applies the unified diff to the workspace files.
+// All the hunks are matched before any file is written, the hunk position may be off if the context matches.
+func (t *FileTools) ApplyPatch(patch string) (string, error) {
+	patches, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return "", err
+	}
+
+	// The patches of the same file are applied one after another to its latest content
+	type fileChange struct {
+		abs     string
+		content string
+		remove  bool
+		// existed is set if the file is on the disk before the patch
+		existed bool
+	}
+	changes := []*fileChange{}
+	byPath := map[string]*fileChange{}
+	summary := []string{}
+	for _, p := range patches {
+		path := p.newPath
+		if path == "" {
+			path = p.oldPath
+		}
+		abs, err := t.resolve(path)
+		if err != nil {
+			return "", err
+		}
+
+		change, ok := byPath[abs]
+		if !ok {
+			change = &fileChange{abs: abs}
+			data, err := os.ReadFile(abs)
+			switch {
+			case err == nil:
+				change.existed = true
+				change.content = string(data)
+			case !errors.Is(err, fs.ErrNotExist):
+				return "", err
+			default:
+				change.remove = true
+			}
+			byPath[abs] = change
+			changes = append(changes, change)
+		}
+
+		exists := !change.remove
+		switch {
+		case p.oldPath != "" && !exists:
+			return "", fmt.Errorf("%s: file does not exist", path)
+		case p.oldPath == "" && exists:
+			return "", fmt.Errorf("%s: file already exists", path)
+		}
+		original := ""
+		if p.oldPath != "" {
+			original = change.content
+		}
+
+		content, err := applyHunks(original, p.hunks)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		change.content = content
+		change.remove = p.newPath == ""
+
+		switch {
+		case p.newPath == "":
+			summary = append(summary, "deleted "+path)
+		case p.oldPath == "":
+			summary = append(summary, "created "+path)
+		default:
+			summary = append(summary, "patched "+path)
+		}
+	}
+
+	for _, change := range changes {
+		if change.remove {
+			if !change.existed {
+				continue
+			}
+			if err := os.Remove(change.abs); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.abs), 0o755); err != nil {
+			return "", err
+		}
+		if err := writeFileNoFollow(change.abs, []byte(change.content), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(change.abs)); err != nil {
+			return "", err
+		}
+	}
+
+	log.Debug().Msgf("file tools: patch applied: %s", strings.Join(summary, ", "))
+	return strings.Join(summary, "\n"), nil
+}
+
+func (t *FileTools) callApplyEdit(ctx context.Context, input string) (string, error) {
+	args := ApplyEditArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+
+	switch {
+	case args.Patch != "":
+		return t.ApplyPatch(args.Patch)
+	case args.Blocks != "":
+		edits, err := parseSearchReplaceBlocks(args.Blocks)
+		if err != nil {
+			return "", err
+		}
+		return t.ApplySearchReplace(args.Path, edits)
+	case args.Search != "":
+		return t.ApplySearchReplace(args.Path, []SearchReplace{{
+			Search:  args.Search,
+			Replace: args.Replace,
+		}})
+	}
+	return "", errors.New("one of search, blocks or patch arguments is required")
+}
+
+// parseSearchReplaceBlocks parses the search and replace blocks, ignoring the text around them.
+func parseSearchReplaceBlocks(text string) ([]SearchReplace, error) {
+	edits := []SearchReplace{}
+	lines := strings.SplitAfter(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != searchBlockStart {
+			continue
+		}
+
+		search, replace := strings.Builder{}, strings.Builder{}
+		current := &search
+		closed := false
+		for i++; i < len(lines); i++ {
+			marker := strings.TrimSpace(lines[i])
+			if marker == searchBlockDivide && current == &search {
+				current = &replace
+				continue
+			}
+			if marker == searchBlockEnd && current == &replace {
+				closed = true
+				break
+			}
+			current.WriteString(lines[i])
+		}
+		if !closed {
+			return nil, fmt.Errorf("block %d is not closed with %s", len(edits)+1, searchBlockEnd)
+		}
+		edits = append(edits, SearchReplace{
+			Search:  search.String(),
+			Replace: replace.String(),
+		})
+	}
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("no %s blocks found", searchBlockStart)
+	}
+	return edits, nil
+}
+
+// parseUnifiedDiff parses the file patches, every hunk consumes the number of lines given by its header,
+// so the removed and added lines looking like the file headers are kept in the hunk.
+func parseUnifiedDiff(patch string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	patches := []filePatch{}
+
+	var current *filePatch
+	var currentHunk *hunk
+	// The old and new lines left to read in the current hunk
+	oldLeft, newLeft := 0, 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if currentHunk != nil && (oldLeft > 0 || newLeft > 0) {
+			op := byte(' ')
+			text := ""
+			switch {
+			case line == "":
+				// Empty context lines often lose their leading space
+			case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+				op, text = line[0], line[1:]
+			case line[0] == '\\':
+				markNoEOL(currentHunk)
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: hunk %d ends early, %d old and %d new lines are missing",
+					i+1, len(current.hunks), oldLeft, newLeft)
+			}
+			if op != '+' {
+				oldLeft--
+			}
+			if op != '-' {
+				newLeft--
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("line %d: hunk %d has more lines than its header counts", i+1, len(current.hunks))
+			}
+			currentHunk.lines = append(currentHunk.lines, hunkLine{op: op, text: text})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			patches = append(patches, filePatch{
+				oldPath: diffPath(line[4:]),
+				newPath: diffPath(lines[i+1][4:]),
+			})
+			current = &patches[len(patches)-1]
+			currentHunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without the file header", i+1)
+			}
+			matches := hunkHeaderPattern.FindStringSubmatch(line)
+			if matches == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, line)
+			}
+			oldStart, _ := strconv.Atoi(matches[1])
+			oldLeft, newLeft = hunkCount(matches[2]), hunkCount(matches[4])
+			current.hunks = append(current.hunks, hunk{oldStart: oldStart})
+			currentHunk = &current.hunks[len(current.hunks)-1]
+		case currentHunk != nil && strings.HasPrefix(line, `\`):
+			// The marker follows the last line of the hunk
+			markNoEOL(currentHunk)
+		case currentHunk != nil && line != "" && strings.IndexByte(" -+", line[0]) != -1:
+			return nil, fmt.Errorf("line %d: hunk %d has more lines than its header counts", i+1, len(current.hunks))
+		default:
+			// git headers and the text around the diff
+			currentHunk = nil
+		}
+	}
+	if currentHunk != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("hunk %d of %s ends early, %d old and %d new lines are missing",
+			len(current.hunks), current.newPath, oldLeft, newLeft)
+	}
+
+	if len(patches) == 0 {
+		return nil, errors.New("no file headers found in the patch, expected --- and +++ lines")
+	}
+	for _, p := range patches {
+		if p.oldPath == "" && p.newPath == "" {
+			return nil, errors.New("both patch paths are /dev/null")
+		}
+		if len(p.hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks found", p.newPath)
+		}
+	}
+	return patches, nil
+}
+
+// hunkCount returns the hunk header line count, which is 1 if omitted.
+func hunkCount(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// markNoEOL applies the "\ No newline at end of file" marker to the side of the last hunk line.
+func markNoEOL(h *hunk) {
+	if len(h.lines) == 0 {
+		return
+	}
+	switch h.lines[len(h.lines)-1].op {
+	case '-':
+		h.oldNoEOL = true
+	case '+':
+		h.newNoEOL = true
+	default:
+		h.oldNoEOL = true
+		h.newNoEOL = true
+	}
+}
+
+// diffPath returns the path from the diff file header, stripping the timestamp and the git a/ b/ prefixes.
+func diffPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	for _, prefix := range []string{"a/", "b/"} {
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
+
+// applyHunks applies the hunks in order, looking for the hunk old lines nearest to its header position.
+func applyHunks(content string, hunks []hunk) (string, error) {
+	lines := []string{}
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	eol := content == "" || strings.HasSuffix(content, "\n")
+
+	offset := 0
+	for idx, h := range hunks {
+		oldLines, newLines := []string{}, []string{}
+		for _, line := range h.lines {
+			if line.op != '+' {
+				oldLines = append(oldLines, line.text)
+			}
+			if line.op != '-' {
+				newLines = append(newLines, line.text)
+			}
+		}
+
+		expected := max(h.oldStart-1, 0) + offset
+		if len(oldLines) == 0 {
+			// Pure addition, inserted after the old start line
+			expected = min(h.oldStart+offset, len(lines))
+		}
+		pos := findLines(lines, oldLines, expected)
+		if pos < 0 {
+			return "", fmt.Errorf("hunk %d does not match the file content near line %d", idx+1, h.oldStart)
+		}
+
+		end := pos + len(oldLines)
+		if end == len(lines) {
+			if h.newNoEOL {
+				eol = false
+			} else if h.oldNoEOL || len(newLines) > 0 {
+				eol = true
+			}
+		}
+
+		updated := append([]string{}, lines[:pos]...)
+		updated = append(updated, newLines...)
+		lines = append(updated, lines[end:]...)
+		offset += len(newLines) - len(oldLines)
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if eol {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findLines returns the position of the needle lines nearest to the expected one or -1.
+// Exact matches are preferred over the ones ignoring the trailing whitespace.
+func findLines(lines, needle []string, expected int) int {
+	if len(needle) == 0 {
+		return expected
+	}
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		matchAt := func(pos int) bool {
+			if pos < 0 || pos+len(needle) > len(lines) {
+				return false
+			}
+			for i, line := range needle {
+				if !equal(lines[pos+i], line) {
+					return false
+				}
+			}
+			return true
+		}
+		for delta := 0; delta <= len(lines); delta++ {
+			if matchAt(expected - delta) {
+				return expected - delta
+			}
+			if matchAt(expected + delta) {
+				return expected + delta
+			}
+		}
+	}
+	return -1
+}
+
+// fileMode returns the existing file permissions to keep them on rewrite.
+func fileMode(path string) fs.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return 0o644
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		patch   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "lines looking like file headers",
+			files: map[string]string{"a.txt": "one\n-- x\nthree\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+--- x
++++ y
+ three
+`,
+			want: map[string]string{"a.txt": "one\n++ y\nthree\n"},
+		},
+		{
+			name:  "trailing blank context line",
+			files: map[string]string{"a.txt": "one\ntwo\n\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+
+`,
+			want: map[string]string{"a.txt": "one\n2\n\n"},
+		},
+		{
+			name:  "patches of the same file",
+			files: map[string]string{"a.txt": "one\ntwo\nthree\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++1
+--- a/a.txt
++++ b/a.txt
+@@ -3 +3 @@
+-three
++3
+`,
+			want: map[string]string{"a.txt": "1\ntwo\n3\n"},
+		},
+		{
+			name:  "create and delete",
+			files: map[string]string{"old.txt": "gone\n"},
+			patch: `--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++first
++second
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`,
+			want: map[string]string{"new.txt": "first\nsecond\n", "old.txt": ""},
+		},
+		{
+			name:  "no newline at end of file",
+			files: map[string]string{"a.txt": "one\ntwo"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -2 +2 @@
+-two
+\ No newline at end of file
++2
+`,
+			want: map[string]string{"a.txt": "one\n2\n"},
+		},
+		{
+			name:  "hunk shorter than its header",
+			files: map[string]string{"a.txt": "one\ntwo\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+diff --git a/b.txt b/b.txt`,
+			wantErr: "ends early",
+		},
+		{
+			name:  "hunk longer than its header",
+			files: map[string]string{"a.txt": "one\ntwo\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+ one
+-two
+`,
+			wantErr: "more lines than its header",
+		},
+		{
+			name:  "mismatch leaves the files unchanged",
+			files: map[string]string{"a.txt": "one\n", "b.txt": "two\n"},
+			patch: `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++1
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-three
++3
+`,
+			want:    map[string]string{"a.txt": "one\n", "b.txt": "two\n"},
+			wantErr: "does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := (&FileTools{Root: root}).ApplyPatch(tt.patch)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(root, name))
+				if want == "" {
+					if err == nil {
+						t.Errorf("%s exists, want it deleted", name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s content %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
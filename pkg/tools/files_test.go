@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Swarmind/libagent/pkg/config"
+)
+
+func TestFileToolsResolveSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"inside":         "dir",
+		"relative":       "../dir/file",
+		"outside":        outside,
+		"dangling":       filepath.Join(outside, "missing"),
+		"dir/escape":     "../../" + filepath.Base(outside),
+		"dir/up":         "..",
+		"loop":           "loop",
+		"danglingInside": "dir/new",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"file", "file"},
+		{"dir/new/file", "dir/new/file"},
+		{"inside/file", "dir/file"},
+		{"dir/up/dir/file", "dir/file"},
+		{"danglingInside", "dir/new"},
+		{"relative", ""},
+		{"outside/file", ""},
+		{"dangling", ""},
+		{"dir/escape/file", ""},
+		{"loop", ""},
+		{"../file", ""},
+		{"/etc/passwd", ""},
+	}
+	fileTools := &FileTools{Root: root}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := fileTools.resolve(tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("resolved to %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := filepath.Join(root, tt.want); got != want {
+				t.Errorf("resolved to %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFileToolsWriteDanglingSymlink(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(t.TempDir(), "x")
+	if err := os.Symlink(target, filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	fileTools := &FileTools{Root: root}
+	if _, err := fileTools.WriteFile("a", "data", false); err == nil {
+		t.Errorf("write through the dangling symlink succeeded")
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Errorf("file outside of the workspace was created")
+	}
+}
+
+func TestShareWorkspace(t *testing.T) {
+	seed := filepath.Join(t.TempDir(), "seed.txt")
+	if err := os.WriteFile(seed, []byte("seed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		// shared is the new temporary directory of both tools
+		shared = "shared"
+		// own is the new temporary root of the file tools
+		own = "own"
+	)
+	tests := []struct {
+		name      string
+		cfg       config.Config
+		whitelist []string
+		root      string
+		dir       string
+	}{
+		{"both enabled", config.Config{}, nil, shared, shared},
+		{"both whitelisted", config.Config{}, []string{"writeFile", "commandExecutor"}, shared, shared},
+		{"command executor only", config.Config{}, []string{"commandExecutor"}, "", ""},
+		{"file tools only", config.Config{}, []string{"readFile"}, own, ""},
+		{"command executor disabled", config.Config{CommandExecutorDisable: true}, nil, own, ""},
+		{"file tools disabled", config.Config{FileToolsDisable: true}, nil, "", ""},
+		{"root configured", config.Config{FileToolsRoot: "/root"}, nil, "/root", "/root"},
+		{"root and dir configured", config.Config{FileToolsRoot: "/root", CommandExecutorWorkspaceDir: "/dir"}, nil, "/root", "/dir"},
+		{"dir configured", config.Config{CommandExecutorWorkspaceDir: "/dir"}, nil, "/dir", "/dir"},
+		{"dir of the command executor only", config.Config{CommandExecutorWorkspaceDir: "/dir"}, []string{"grep"}, own, "/dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared := &sharedResources{values: map[string]any{}}
+			ctx := context.WithValue(context.Background(), sharedResourcesKey{}, shared)
+			cfg := tt.cfg
+			cfg.CommandExecutorWorkspaceSeed = []string{seed}
+			options := ExecutorOptions{ToolsWhitelist: tt.whitelist}
+			if err := shareWorkspace(ctx, &cfg, options.enabled); err != nil {
+				t.Fatal(err)
+			}
+
+			switch tt.root {
+			case "shared":
+				if cfg.FileToolsRoot == "" || cfg.FileToolsRoot != cfg.CommandExecutorWorkspaceDir {
+					t.Fatalf("root %q, dir %q, want the same directory", cfg.FileToolsRoot, cfg.CommandExecutorWorkspaceDir)
+				}
+				if data, err := os.ReadFile(filepath.Join(cfg.FileToolsRoot, "seed.txt")); string(data) != "seed" {
+					t.Errorf("seed %q, error %v, want the seeded workspace", data, err)
+				}
+				for _, cleanup := range shared.cleanups {
+					if err := cleanup(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if _, err := os.Stat(cfg.FileToolsRoot); !os.IsNotExist(err) {
+					t.Errorf("shared workspace is not removed: %v", err)
+				}
+			case "own":
+				if !strings.HasPrefix(filepath.Base(cfg.FileToolsRoot), "libagent_workspace_") || cfg.FileToolsRoot == tt.dir {
+					t.Errorf("root %q, want the own temporary directory", cfg.FileToolsRoot)
+				}
+				if _, err := os.Stat(cfg.FileToolsRoot); !os.IsNotExist(err) {
+					t.Errorf("own root is created before the first use: %v", err)
+				}
+				if cfg.CommandExecutorWorkspaceDir != tt.dir {
+					t.Errorf("dir %q, want %q", cfg.CommandExecutorWorkspaceDir, tt.dir)
+				}
+			default:
+				if cfg.FileToolsRoot != tt.root || cfg.CommandExecutorWorkspaceDir != tt.dir {
+					t.Errorf("root %q, dir %q, want %q and %q", cfg.FileToolsRoot, cfg.CommandExecutorWorkspaceDir, tt.root, tt.dir)
+				}
+			}
+		})
+	}
+}
+
+func TestFileToolsCommandExecutorWorkspace(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep %t", keep), func(t *testing.T) {
+			// The tools needing the services are disabled
+			cfg := config.Config{
+				SemanticSearchDisable:        true,
+				SemanticIndexDisable:         true,
+				MemoryDisable:                true,
+				ReWOODisable:                 true,
+				CommandExecutorWorkspaceKeep: keep,
+			}
+			toolsExecutor, err := NewToolsExecutor(context.Background(), cfg,
+				WithToolsWhitelist(WriteFileDefinition.Name, ReadFileDefinition.Name, CommandExecutorDefinition.Name),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readFile, _ := toolsExecutor.GetTool(ReadFileDefinition.Name)
+			writeFile, _ := toolsExecutor.GetTool(WriteFileDefinition.Name)
+			if readFile.Tool != writeFile.Tool {
+				t.Errorf("file tools do not share the FileTools")
+			}
+
+			if _, err := toolsExecutor.CallTool(context.Background(), WriteFileDefinition.Name, `{"path": "main.go", "content": "package main\n"}`); err != nil {
+				t.Fatal(err)
+			}
+			output, err := toolsExecutor.CallTool(context.Background(), CommandExecutorDefinition.Name, `{"command": "cat main.go; pwd"}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := CommandExecutor(toolsExecutor).Workspace.Dir
+			if dir == "" || !strings.Contains(output, "package main") || !strings.Contains(output, dir) {
+				t.Fatalf("output %q, want the written file in the workspace %q", output, dir)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := toolsExecutor.Cleanup(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "main.go")); (err == nil) != keep {
+				t.Errorf("workspace kept %t, want %t", err == nil, keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strings"
+)
+
+// Metadata keys of the Go declaration chunks.
+const (
+	IndexMetadataPackage  = "package"
+	IndexMetadataKind     = "kind"
+	IndexMetadataSymbol   = "symbol"
+	IndexMetadataReceiver = "receiver"
+	IndexMetadataDoc      = "doc"
+)
+
+// GoChunker splits the Go sources into the top-level declarations, one chunk each,
+// with the package, the symbol, the receiver type and the doc comment in the chunk metadata.
+// The package clause and the imports form the first chunk.
+type GoChunker struct {
+	// Fallback splits the non-Go and unparsable files and the declarations longer than MaxChars,
+	// LineChunker if nil
+	Fallback Chunker
+	// MaxChars is DefaultChunkMaxChars if zero
+	MaxChars int
+}
+
+func (c GoChunker) Chunk(filePath string, content []byte) []Chunk {
+	maxChars := c.MaxChars
+	if maxChars <= 0 {
+		maxChars = DefaultChunkMaxChars
+	}
+	fallback := c.Fallback
+	if fallback == nil {
+		fallback = LineChunker{MaxChars: maxChars, OverlapLines: DefaultChunkOverlapLines}
+	}
+	if path.Ext(filePath) != ".go" {
+		return fallback.Chunk(filePath, content)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.ParseComments)
+	if err != nil {
+		return fallback.Chunk(filePath, content)
+	}
+	pkg := file.Name.Name
+
+	var chunks []Chunk
+	add := func(start, end token.Pos, metadata map[string]any) {
+		metadata[IndexMetadataPackage] = pkg
+		startPos, endPos := fset.Position(start), fset.Position(end)
+		text := content[startPos.Offset:endPos.Offset]
+		if len(text) <= maxChars {
+			chunks = append(chunks, Chunk{
+				Content:   string(text),
+				StartLine: startPos.Line,
+				EndLine:   endPos.Line,
+				Metadata:  metadata,
+			})
+			return
+		}
+		// The long declaration is split, every part keeps its metadata
+		for _, part := range fallback.Chunk(filePath, text) {
+			part.StartLine += startPos.Line - 1
+			part.EndLine += startPos.Line - 1
+			part.Metadata = metadata
+			chunks = append(chunks, part)
+		}
+	}
+
+	// The package doc, the package clause and the imports
+	headerStart, headerEnd := file.Package, file.Name.End()
+	if file.Doc != nil {
+		headerStart = file.Doc.Pos()
+	}
+	decls := file.Decls
+	for len(decls) > 0 {
+		gen, ok := decls[0].(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		headerEnd = gen.End()
+		decls = decls[1:]
+	}
+	add(headerStart, headerEnd, map[string]any{
+		IndexMetadataKind:   "package",
+		IndexMetadataSymbol: pkg,
+		IndexMetadataDoc:    docText(file.Doc),
+	})
+
+	for _, decl := range decls {
+		start := decl.Pos()
+		metadata := map[string]any{}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			metadata[IndexMetadataKind] = "func"
+			metadata[IndexMetadataSymbol] = decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				receiver := receiverType(decl.Recv.List[0].Type)
+				metadata[IndexMetadataKind] = "method"
+				metadata[IndexMetadataSymbol] = receiver + "." + decl.Name.Name
+				metadata[IndexMetadataReceiver] = receiver
+			}
+			metadata[IndexMetadataDoc] = docText(decl.Doc)
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			metadata[IndexMetadataKind] = decl.Tok.String()
+			metadata[IndexMetadataSymbol] = strings.Join(specNames(decl), ", ")
+			doc := docText(decl.Doc)
+			// The docs of the grouped declaration are attached to its specs
+			if decl.Doc == nil {
+				doc = specDocs(decl)
+			}
+			metadata[IndexMetadataDoc] = doc
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+		default:
+			continue
+		}
+		add(start, decl.End(), metadata)
+	}
+	return chunks
+}
+
+// receiverType returns the base type name of the method receiver, without the pointer and the type parameters.
+func receiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func specNames(decl *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, spec.Name.Name)
+		case *ast.ValueSpec:
+			for _, name := range spec.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// specDocs joins the doc comments of the declaration specs.
+func specDocs(decl *ast.GenDecl) string {
+	var docs []string
+	for _, spec := range decl.Specs {
+		var doc *ast.CommentGroup
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			doc = spec.Doc
+		case *ast.ValueSpec:
+			doc = spec.Doc
+		}
+		if text := docText(doc); text != "" {
+			docs = append(docs, text)
+		}
+	}
+	return strings.Join(docs, "\n")
+}
+
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Text())
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// wantChunk is the expected Go declaration chunk.
+type wantChunk struct {
+	kind, symbol, receiver, doc string
+	start, end                  int
+}
+
+func checkChunks(t *testing.T, chunks []Chunk, pkg string, want []wantChunk) {
+	t.Helper()
+	if len(chunks) != len(want) {
+		t.Fatalf("%d chunks %+v, want %d", len(chunks), chunks, len(want))
+	}
+	for i, chunk := range chunks {
+		got := wantChunk{
+			kind:   chunk.Metadata[IndexMetadataKind].(string),
+			symbol: chunk.Metadata[IndexMetadataSymbol].(string),
+			doc:    chunk.Metadata[IndexMetadataDoc].(string),
+			start:  chunk.StartLine,
+			end:    chunk.EndLine,
+		}
+		got.receiver, _ = chunk.Metadata[IndexMetadataReceiver].(string)
+		if got != want[i] {
+			t.Errorf("chunk %d %+v, want %+v", i, got, want[i])
+		}
+		if chunk.Metadata[IndexMetadataPackage] != pkg {
+			t.Errorf("chunk %d package %v, want %s", i, chunk.Metadata[IndexMetadataPackage], pkg)
+		}
+	}
+}
+
+func TestGoChunkerGeneric(t *testing.T) {
+	chunks := GoChunker{}.Chunk("list/list.go", readTestdata(t, "chunker_generic.go"))
+	checkChunks(t, chunks, "list", []wantChunk{
+		{kind: "package", symbol: "list", doc: "Package list is the generic list.", start: 1, end: 4},
+		{kind: "type", symbol: "List", doc: "List keeps the items in order.", start: 6, end: 9},
+		{kind: "method", symbol: "List.Push", receiver: "List", doc: "Push appends the item.", start: 11, end: 14},
+		{kind: "type", symbol: "Pair", doc: "Pair is the key and the value.", start: 16, end: 20},
+		{kind: "method", symbol: "Pair.Less", receiver: "Pair", doc: "Less compares the keys.", start: 22, end: 25},
+		{kind: "func", symbol: "New", start: 27, end: 29},
+	})
+	if !strings.HasPrefix(chunks[4].Content, "// Less compares the keys.\nfunc (p Pair[K, V]) Less(") {
+		t.Errorf("method chunk %q, want it to start with the doc comment", chunks[4].Content)
+	}
+}
+
+func TestGoChunkerGroupedDeclarations(t *testing.T) {
+	chunks := GoChunker{}.Chunk("io/io.go", readTestdata(t, "chunker_grouped.go"))
+	checkChunks(t, chunks, "io", []wantChunk{
+		{kind: "package", symbol: "io", start: 1, end: 1},
+		{kind: "type", symbol: "Reader, Writer", doc: "Reader reads the bytes.\nWriter writes the bytes.", start: 3, end: 13},
+		{kind: "type", symbol: "Seeker", doc: "Seeker moves the offset.", start: 15, end: 20},
+		{kind: "const", symbol: "SeekStart, SeekEnd", doc: "SeekStart is relative to the start.", start: 22, end: 26},
+	})
+}
+
+func TestGoChunkerLongDeclaration(t *testing.T) {
+	content := readTestdata(t, "chunker_long.go")
+	lines := strings.Split(string(content), "\n")
+	const maxChars = 200
+
+	chunks := GoChunker{MaxChars: maxChars}.Chunk("long.go", content)
+	if len(chunks) < 4 {
+		t.Fatalf("%d chunks, want the declaration split", len(chunks))
+	}
+	parts, after := chunks[1:len(chunks)-1], chunks[len(chunks)-1]
+	if parts[0].StartLine != 3 || parts[len(parts)-1].EndLine != 47 {
+		t.Errorf("parts span lines %d-%d, want the declaration lines 3-47", parts[0].StartLine, parts[len(parts)-1].EndLine)
+	}
+	for i, part := range parts {
+		if part.Metadata[IndexMetadataSymbol] != "Sum" || part.Metadata[IndexMetadataDoc] != "Sum adds the numbers one by one." {
+			t.Errorf("part %d metadata %v, want the declaration metadata", i, part.Metadata)
+		}
+		if len(part.Content) > maxChars {
+			t.Errorf("part %d has %d bytes, want at most %d", i, len(part.Content), maxChars)
+		}
+		// The part lines are the file lines
+		want := strings.Join(lines[part.StartLine-1:part.EndLine], "\n")
+		if got := strings.TrimSuffix(part.Content, "\n"); got != want {
+			t.Errorf("part %d lines %d-%d content %q, want %q", i, part.StartLine, part.EndLine, got, want)
+		}
+		if i > 0 && part.StartLine > parts[i-1].EndLine+1 {
+			t.Errorf("part %d starts at line %d, after the gap from line %d", i, part.StartLine, parts[i-1].EndLine)
+		}
+	}
+	if after.Metadata[IndexMetadataSymbol] != "After" || after.StartLine != 49 || after.EndLine != 49 {
+		t.Errorf("last chunk %+v, want the After declaration at line 49", after)
+	}
+}
+
+func TestGoChunkerFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		testdata string
+		end      int
+	}{
+		{"not go", "docs/notes.md", "chunker_notes.md", 4},
+		{"unparsable", "broken.go", "chunker_invalid.txt", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := readTestdata(t, tt.testdata)
+			chunks := GoChunker{}.Chunk(tt.path, content)
+			if len(chunks) != 1 || chunks[0].StartLine != 1 || chunks[0].EndLine != tt.end || chunks[0].Content != string(content) {
+				t.Fatalf("chunks %+v, want the single line chunk of the file", chunks)
+			}
+			if chunks[0].Metadata[IndexMetadataSymbol] != nil {
+				t.Errorf("metadata %v, want no declaration metadata", chunks[0].Metadata)
+			}
+		})
+	}
+}
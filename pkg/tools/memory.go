@@ -0,0 +1,340 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const (
+	// DefaultMemoryNamespace is used when neither the call nor the config set the namespace.
+	DefaultMemoryNamespace = "default"
+	// DefaultMemoryResults is the number of the recalled memories.
+	DefaultMemoryResults = 5
+	// MemoryCollectionPrefix starts the names of the memory collections, one per namespace.
+	MemoryCollectionPrefix = "libagent_memory_"
+)
+
+// Memory kinds.
+const (
+	MemoryKindFact       = "fact"
+	MemoryKindOutcome    = "outcome"
+	MemoryKindPreference = "preference"
+)
+
+// Metadata keys of the memories.
+const (
+	MemoryMetadataKind      = "kind"
+	MemoryMetadataCreatedAt = "created_at"
+)
+
+// The stored memories this similar to the new one are reported to the model, which may update them
+const memorySimilarScore = 0.95
+
+// The number of the similar memories reported by remember
+const memorySimilarResults = 3
+
+var RememberDefinition = llms.FunctionDefinition{
+	Name: "remember",
+	Description: `Stores a memory kept between the runs: a fact, the outcome of a task or a user preference.
+Write one self-contained statement per call, it is recalled later by its meaning.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "the statement to remember",
+			},
+			"kind": map[string]any{
+				"type":        "string",
+				"description": "optional kind of the memory: fact, outcome or preference",
+			},
+			"namespace": map[string]any{
+				"type":        "string",
+				"description": "optional scope of the memory, like the user or the project name",
+			},
+		},
+	},
+}
+
+var RecallDefinition = llms.FunctionDefinition{
+	Name:        "recall",
+	Description: "Returns the stored memories relevant to the query: facts, past task outcomes and user preferences.",
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "what to recall",
+			},
+			"namespace": map[string]any{
+				"type":        "string",
+				"description": "optional scope of the memories, like the user or the project name",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": "optional number of the memories",
+			},
+		},
+	},
+}
+
+type RememberArgs struct {
+	Content   string `json:"content"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type RecallArgs struct {
+	Query      string `json:"query"`
+	Namespace  string `json:"namespace,omitempty"`
+	MaxResults int    `json:"max_results,omitempty"`
+}
+
+// MemoryRecord is the recalled memory.
+type MemoryRecord struct {
+	Content   string
+	Kind      string
+	CreatedAt time.Time
+	Score     float32
+}
+
+// Memory keeps the agent memories as embeddings in the vector store, every namespace in its own collection.
+type Memory struct {
+	Store VectorStore
+	// Namespace is used when the call does not set one, DefaultMemoryNamespace if empty
+	Namespace string
+	// MaxResults is DefaultMemoryResults if zero
+	MaxResults int
+	// MinScore drops the recalled memories less similar to the query
+	MinScore float32
+}
+
+// NewMemory returns the memory on the configured semantic search store.
+func NewMemory(cfg config.Config) (*Memory, error) {
+	store, err := NewVectorStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %w", err)
+	}
+	return newMemory(store, cfg), nil
+}
+
+func newMemory(store VectorStore, cfg config.Config) *Memory {
+	return &Memory{
+		Store:      store,
+		Namespace:  cfg.MemoryNamespace,
+		MaxResults: cfg.MemoryMaxResults,
+		MinScore:   float32(cfg.MemoryMinScore),
+	}
+}
+
+// RememberResult reports the outcome of Remember.
+type RememberResult struct {
+	// Stored is false if the memory with the same content, ignoring the case and the whitespace, is already stored
+	Stored bool
+	// Similar are the stored memories close to the new one, which may be its outdated or contradicting versions
+	Similar []MemoryRecord
+}
+
+// Remember stores the memory unless the same one is already stored, reporting the similar stored memories.
+func (m *Memory) Remember(ctx context.Context, namespace, kind, content string) (RememberResult, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return RememberResult{}, fmt.Errorf("empty memory")
+	}
+	if kind == "" {
+		kind = MemoryKindFact
+	}
+	if !slices.Contains([]string{MemoryKindFact, MemoryKindOutcome, MemoryKindPreference}, kind) {
+		return RememberResult{}, fmt.Errorf("unknown memory kind %q", kind)
+	}
+	collection := m.collection(namespace)
+
+	similar, err := m.Store.SimilaritySearch(ctx, collection, content, SemanticFilter{}, memorySimilarScore, memorySimilarResults)
+	if err != nil {
+		return RememberResult{}, err
+	}
+	result := RememberResult{}
+	for _, s := range similar {
+		if strings.EqualFold(normalizeSpace(s.Content), normalizeSpace(content)) {
+			return RememberResult{}, nil
+		}
+		result.Similar = append(result.Similar, memoryRecord(s))
+	}
+
+	err = m.Store.AddDocuments(ctx, collection, []schema.Document{{
+		PageContent: content,
+		Metadata: map[string]any{
+			MemoryMetadataKind:      kind,
+			MemoryMetadataCreatedAt: time.Now().UTC().Format(time.RFC3339),
+		},
+	}})
+	if err != nil {
+		return RememberResult{}, err
+	}
+	result.Stored = true
+	return result, m.Store.Flush(ctx)
+}
+
+// Recall returns the memories of the namespace most similar to the query, at most maxResults if it is positive.
+func (m *Memory) Recall(ctx context.Context, namespace, query string, maxResults int) ([]MemoryRecord, error) {
+	if maxResults <= 0 {
+		maxResults = m.MaxResults
+	}
+	if maxResults <= 0 {
+		maxResults = DefaultMemoryResults
+	}
+
+	results, err := m.Store.SimilaritySearch(ctx, m.collection(namespace), query, SemanticFilter{}, m.MinScore, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]MemoryRecord, len(results))
+	for i, result := range results {
+		records[i] = memoryRecord(result)
+	}
+	return records, nil
+}
+
+func memoryRecord(result SemanticResult) MemoryRecord {
+	record := MemoryRecord{Content: result.Content, Score: result.Score}
+	record.Kind, _ = result.Metadata[MemoryMetadataKind].(string)
+	if createdAt, ok := result.Metadata[MemoryMetadataCreatedAt].(string); ok {
+		record.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	}
+	return record
+}
+
+// RecallContext returns the memories of the default namespace relevant to the input, formatted for the prompt,
+// or an empty string if there are none.
+func (m *Memory) RecallContext(ctx context.Context, input string) (string, error) {
+	records, err := m.Recall(ctx, "", input, 0)
+	if err != nil || len(records) == 0 {
+		return "", err
+	}
+	return "Relevant memories from the previous runs:\n" + renderMemories(records), nil
+}
+
+func (m *Memory) collection(namespace string) string {
+	if namespace == "" {
+		namespace = m.Namespace
+	}
+	if namespace == "" {
+		namespace = DefaultMemoryNamespace
+	}
+	return MemoryCollectionPrefix + namespace
+}
+
+func renderMemories(records []MemoryRecord) string {
+	var sb strings.Builder
+	for _, record := range records {
+		sb.WriteString("- [" + record.Kind)
+		if !record.CreatedAt.IsZero() {
+			sb.WriteString(", " + record.CreatedAt.Format(time.DateOnly))
+		}
+		sb.WriteString("] " + record.Content + "\n")
+	}
+	return sb.String()
+}
+
+// MemoryTool is the remember and recall tools.
+type MemoryTool struct {
+	Memory *Memory
+}
+
+func (t MemoryTool) Remember(ctx context.Context, input string) (string, error) {
+	args := RememberArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	result, err := t.Memory.Remember(ctx, args.Namespace, args.Kind, args.Content)
+	if err != nil {
+		return "", err
+	}
+	if !result.Stored {
+		return "Already remembered.", nil
+	}
+	if len(result.Similar) > 0 {
+		return "Remembered. Similar memories are already stored, they may be outdated by the new one:\n" +
+			renderMemories(result.Similar), nil
+	}
+	return "Remembered.", nil
+}
+
+func (t MemoryTool) Recall(ctx context.Context, input string) (string, error) {
+	args := RecallArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	records, err := t.Memory.Recall(ctx, args.Namespace, args.Query, args.MaxResults)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "No memories found.", nil
+	}
+	return renderMemories(records), nil
+}
+
+// sharedMemory returns the memory shared by the remember and recall tools of the executor,
+// on the vector store of the executor.
+func sharedMemory(ctx context.Context, cfg config.Config) (*Memory, error) {
+	store, err := sharedVectorStore(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %w", err)
+	}
+	return sharedResource(ctx, "memory", func() (*Memory, func() error, error) {
+		return newMemory(store, cfg), nil, nil
+	})
+}
+
+func init() {
+	globalToolsRegistry = append(globalToolsRegistry,
+		func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+			if cfg.MemoryDisable || cfg.SemanticSearchDisable {
+				return nil, nil
+			}
+			if cfg.SemanticSearchEmbeddingModel == "" {
+				return nil, fmt.Errorf("memory empty embedding model")
+			}
+			memory, err := sharedMemory(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			memoryTool := MemoryTool{Memory: memory}
+
+			return &tools.ToolData{
+				Definition: RememberDefinition,
+				Call:       memoryTool.Remember,
+			}, nil
+		},
+		func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+			if cfg.MemoryDisable || cfg.SemanticSearchDisable {
+				return nil, nil
+			}
+			if cfg.SemanticSearchEmbeddingModel == "" {
+				return nil, fmt.Errorf("memory empty embedding model")
+			}
+			memory, err := sharedMemory(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			memoryTool := MemoryTool{Memory: memory}
+
+			return &tools.ToolData{
+				Definition:  RecallDefinition,
+				Call:        memoryTool.Recall,
+				OutputLimit: cfg.ToolOutputLimit,
+			}, nil
+		},
+	)
+}
@@ -0,0 +1,314 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// MemoryVectorStore keeps the collections in the process memory, searching them by the brute force.
+// If Path is set, the collections are loaded from the file on the first use and saved to it by Flush and Close,
+// as JSON for the .json files and gob otherwise.
+// It is safe for the concurrent use.
+type MemoryVectorStore struct {
+	Embedder embeddings.Embedder
+	Path     string
+
+	mu           sync.Mutex
+	loaded       bool
+	dirty        bool
+	collections  map[string][]memoryDocument
+	descriptions map[string]string
+}
+
+type memoryDocument struct {
+	Content  string
+	Metadata map[string]any
+	Vector   []float32
+}
+
+// memoryStoreFile is the persisted store.
+type memoryStoreFile struct {
+	Collections  map[string][]memoryDocument
+	Descriptions map[string]string
+}
+
+// collection returns the documents of the collection, loading the file if needed, called with mu held.
+func (s *MemoryVectorStore) collection(name string) ([]memoryDocument, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.collections[name], nil
+}
+
+func (s *MemoryVectorStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	s.collections = map[string][]memoryDocument{}
+	s.descriptions = map[string]string{}
+	if s.Path != "" {
+		data, err := os.ReadFile(s.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return fmt.Errorf("load vector store: %w", err)
+		default:
+			file := memoryStoreFile{}
+			if strings.EqualFold(filepath.Ext(s.Path), ".json") {
+				err = json.Unmarshal(data, &file)
+			} else {
+				err = gob.NewDecoder(bytes.NewReader(data)).Decode(&file)
+			}
+			if err != nil {
+				return fmt.Errorf("decode vector store %s: %w", s.Path, err)
+			}
+			if file.Collections != nil {
+				s.collections = file.Collections
+			}
+			if file.Descriptions != nil {
+				s.descriptions = file.Descriptions
+			}
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *MemoryVectorStore) AddDocuments(ctx context.Context, collection string, docs []schema.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.PageContent
+	}
+	// The embedding is done without the lock, it is the slowest part
+	vectors, err := s.Embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(docs) {
+		return fmt.Errorf("embedder returned %d vectors for %d documents", len(vectors), len(docs))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		documents = append(documents, memoryDocument{Content: doc.PageContent, Metadata: doc.Metadata, Vector: vectors[i]})
+	}
+	s.collections[collection] = documents
+	s.dirty = true
+	return nil
+}
+
+func (s *MemoryVectorStore) SimilaritySearch(ctx context.Context, collection, query string, filter SemanticFilter, minScore float32, limit int) ([]SemanticResult, error) {
+	vector, err := s.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.search(collection, filter, limit, func(doc memoryDocument) float32 {
+		if score := cosineSimilarity(vector, doc.Vector); score >= minScore {
+			return score
+		}
+		return 0
+	})
+}
+
+// LexicalSearch scores the documents by the saturated frequencies of the query terms.
+func (s *MemoryVectorStore) LexicalSearch(ctx context.Context, collection, query string, filter SemanticFilter, limit int) ([]SemanticResult, error) {
+	terms := queryTerms(query)
+	return s.search(collection, filter, limit, func(doc memoryDocument) float32 {
+		return float32(passageScore(doc.Content, terms))
+	})
+}
+
+// search returns the filtered documents with the positive score, best first.
+func (s *MemoryVectorStore) search(collection string, filter SemanticFilter, limit int, score func(memoryDocument) float32) ([]SemanticResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SemanticResult
+	for _, doc := range documents {
+		if filter.Language != "" && doc.Metadata[IndexMetadataLanguage] != filter.Language {
+			continue
+		}
+		if path, _ := doc.Metadata[IndexMetadataPath].(string); filter.PathPrefix != "" && !strings.HasPrefix(path, filter.PathPrefix) {
+			continue
+		}
+		docScore := score(doc)
+		if docScore <= 0 {
+			continue
+		}
+		result := semanticResultFromMetadata(doc.Content, doc.Metadata)
+		result.Score = docScore
+		results = append(results, result)
+	}
+	slices.SortStableFunc(results, func(a, b SemanticResult) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return results[:min(len(results), limit)], nil
+}
+
+func (s *MemoryVectorStore) IndexedFiles(ctx context.Context, collection string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed := map[string]string{}
+	for _, doc := range documents {
+		rel, ok := doc.Metadata[IndexMetadataPath].(string)
+		if !ok {
+			continue
+		}
+		var hash *string
+		if value, ok := doc.Metadata[IndexMetadataHash].(string); ok {
+			hash = &value
+		}
+		addIndexedFile(indexed, rel, hash)
+	}
+	return indexed, nil
+}
+
+func (s *MemoryVectorStore) RemoveFiles(ctx context.Context, collection string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents, err := s.collection(collection)
+	if err != nil || len(documents) == 0 {
+		return err
+	}
+
+	s.collections[collection] = slices.DeleteFunc(documents, func(doc memoryDocument) bool {
+		path, _ := doc.Metadata[IndexMetadataPath].(string)
+		return slices.Contains(paths, path)
+	})
+	s.dirty = true
+	return nil
+}
+
+func (s *MemoryVectorStore) Collections(ctx context.Context) ([]CollectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var collections []CollectionInfo
+	for name, documents := range s.collections {
+		collections = append(collections, CollectionInfo{Name: name, Documents: len(documents), Description: s.descriptions[name]})
+	}
+	for name, description := range s.descriptions {
+		if _, ok := s.collections[name]; !ok {
+			collections = append(collections, CollectionInfo{Name: name, Description: description})
+		}
+	}
+	slices.SortFunc(collections, func(a, b CollectionInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return collections, nil
+}
+
+func (s *MemoryVectorStore) SetDescription(ctx context.Context, collection, description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.descriptions[collection] = description
+	s.dirty = true
+	return nil
+}
+
+// Flush saves the collections to the file if they were changed.
+func (s *MemoryVectorStore) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Path == "" || !s.dirty {
+		return nil
+	}
+	var buf bytes.Buffer
+	var err error
+	file := memoryStoreFile{Collections: s.collections, Descriptions: s.descriptions}
+	if strings.EqualFold(filepath.Ext(s.Path), ".json") {
+		err = json.NewEncoder(&buf).Encode(file)
+	} else {
+		err = gob.NewEncoder(&buf).Encode(file)
+	}
+	if err != nil {
+		return fmt.Errorf("encode vector store: %w", err)
+	}
+
+	// Write the whole file at once, so the interrupted save does not corrupt it
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("save vector store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save vector store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save vector store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return fmt.Errorf("save vector store: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close saves the collections.
+func (s *MemoryVectorStore) Close() error {
+	return s.Flush(context.Background())
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+// fakeEmbedder embeds the texts by their length.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = fakeEmbedder{}.EmbedQuery(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (fakeEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, float32(len(text))}, nil
+}
+
+func TestMemoryVectorStoreMissingCollection(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryVectorStore{Embedder: fakeEmbedder{}}
+
+	if results, err := store.SimilaritySearch(ctx, "missing", "query", SemanticFilter{}, 0, 5); err != nil || len(results) != 0 {
+		t.Errorf("results %v, error %v, want none", results, err)
+	}
+	if results, err := store.LexicalSearch(ctx, "missing", "query", SemanticFilter{}, 5); err != nil || len(results) != 0 {
+		t.Errorf("results %v, error %v, want none", results, err)
+	}
+	if err := store.RemoveFiles(ctx, "missing", []string{"a.go"}); err != nil {
+		t.Fatal(err)
+	}
+	collections, err := store.Collections(ctx)
+	if err != nil || len(collections) != 0 {
+		t.Fatalf("collections %v, error %v, want none created by the reads", collections, err)
+	}
+
+	if err := store.AddDocuments(ctx, "docs", []schema.Document{{PageContent: "query"}}); err != nil {
+		t.Fatal(err)
+	}
+	collections, err = store.Collections(ctx)
+	if err != nil || len(collections) != 1 || collections[0].Name != "docs" || collections[0].Documents != 1 {
+		t.Errorf("collections %v, error %v, want the added one", collections, err)
+	}
+}
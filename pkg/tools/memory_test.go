@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Swarmind/libagent/pkg/config"
+)
+
+// vectorsEmbedder returns the fixed vectors of the lowercase texts.
+type vectorsEmbedder map[string][]float32
+
+func (e vectorsEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = e.EmbedQuery(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (e vectorsEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return e[strings.ToLower(normalizeSpace(text))], nil
+}
+
+func TestMemoryRemember(t *testing.T) {
+	ctx := context.Background()
+	embedder := vectorsEmbedder{
+		"the user prefers tea":       {1, 0, 0},
+		"the user prefers green tea": {1, 0.1, 0},
+		"the deploy target is arm64": {0, 0, 1},
+	}
+	store := &MemoryVectorStore{Embedder: embedder}
+	memoryTool := MemoryTool{Memory: &Memory{Store: store}}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"The user prefers tea", "Remembered."},
+		{"  the user PREFERS   tea ", "Already remembered."},
+		{"The user prefers green tea", "Similar memories are already stored"},
+		{"The deploy target is arm64", "Remembered."},
+	}
+	for _, tt := range tests {
+		got, err := memoryTool.Remember(ctx, `{"content": "`+tt.content+`"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("remember %q: %q, want %q", tt.content, got, tt.want)
+		}
+	}
+
+	records, err := memoryTool.Memory.Recall(ctx, "", "the user prefers tea", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The unrelated memory has the zero similarity
+	if len(records) != 2 {
+		t.Errorf("recalled %d memories, want the near duplicate stored too", len(records))
+	}
+}
+
+func TestSharedMemory(t *testing.T) {
+	shared := &sharedResources{values: map[string]any{}}
+	ctx := context.WithValue(context.Background(), sharedResourcesKey{}, shared)
+	cfg := config.Config{SemanticSearchDBConnection: "postgres://localhost/test", SemanticSearchEmbeddingModel: "test"}
+
+	remember, err := sharedMemory(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recall, err := sharedMemory(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remember != recall || len(shared.cleanups) != 1 {
+		t.Errorf("remember and recall do not share the memory store")
+	}
+	store, err := sharedVectorStore(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remember.Store != store || len(shared.cleanups) != 1 {
+		t.Errorf("memory does not share the vector store of the semantic search tools")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/Swarmind/libagent/internal/tools"
@@ -93,8 +93,7 @@ func (s MsfSearchTool) Call(ctx context.Context, input string) (string, error) {
 			execName = msfExecutable
 		}
 
-		cmd := exec.Command(execName, args...)
-		output, err := cmd.CombinedOutput()
+		output, err := runCommand(ctx, execName, args...)
 		if err != nil {
 			return "", fmt.Errorf("failed to execute %s %v: %w\noutput: %s", execName, args, err, string(output))
 		}
@@ -120,13 +119,25 @@ func (s MsfSearchTool) Call(ctx context.Context, input string) (string, error) {
 	return string(respBytes), nil
 }
 
-func GenerateMsfQueries(ports []PortInfo) []string {
+func GenerateMsfQueries(ports []NmapPort) []string {
 	var queries []string
+	// The hosts running the same services produce the same queries
+	addQuery := func(query string) {
+		if !slices.Contains(queries, query) {
+			queries = append(queries, query)
+		}
+	}
 	for _, port := range ports {
-		if strings.ToLower(port.State) == "open" {
-			queries = append(queries, fmt.Sprintf("type:exploit name:%s", port.Service))
-			queries = append(queries, fmt.Sprintf("port %s", port.Port))
+		if strings.ToLower(port.State) != "open" {
+			continue
+		}
+		if port.Service != "" {
+			addQuery(fmt.Sprintf("type:exploit name:%s", port.Service))
+		}
+		if port.Product != "" {
+			addQuery(fmt.Sprintf("type:exploit %s", port.Product))
 		}
+		addQuery(fmt.Sprintf("port:%d", port.Port))
 	}
 
 	return queries
@@ -145,8 +156,9 @@ func init() {
 			}
 
 			return &tools.ToolData{
-				Definition: MsfSearchToolDefinition,
-				Call:       tool.Call,
+				Definition:  MsfSearchToolDefinition,
+				Call:        tool.Call,
+				OutputLimit: cfg.ToolOutputLimit,
 			}, nil
 		},
 	)
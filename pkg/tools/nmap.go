@@ -3,10 +3,11 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os/exec"
-	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/Swarmind/libagent/internal/tools"
@@ -16,7 +17,7 @@ import (
 
 var NmapToolDefinition = llms.FunctionDefinition{
 	Name:        "nmap",
-	Description: "Executes nmap with configurable args (or uses sane defaults), parses the output, and generates Metasploit search queries.",
+	Description: "Executes nmap with configurable args (or uses sane defaults) and returns the scan results as JSON: hosts with their addresses, ports with the protocol, state, service, product, version and CPE, script results and OS matches, plus the generated Metasploit search queries. If the scan fails or times out, the hosts scanned before are returned with the error.",
 	Parameters: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
@@ -43,12 +44,15 @@ type NmapToolArgs struct {
 	Args []string `json:"args,omitempty"`
 }
 
-type PortInfo struct {
-	Port    string
-	State   string
-	Service string
+// NmapToolResult is the JSON returned to the model.
+type NmapToolResult struct {
+	*NmapRun
+	MsfQueries []string `json:"metasploit_queries,omitempty"`
 }
 
+// nmap output options replaced by the XML output to stdout
+var nmapOutputArgs = []string{"-oX", "-oA", "-oN", "-oG", "-oS"}
+
 // Call executes the command with the given arguments.
 func (s NmapTool) Call(ctx context.Context, input string) (string, error) {
 	nmapToolArgs := NmapToolArgs{}
@@ -56,19 +60,49 @@ func (s NmapTool) Call(ctx context.Context, input string) (string, error) {
 		return "", fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 
+	// The partial results of the failed scan are returned with the error in the Error field
+	run, err := s.Scan(ctx, nmapToolArgs)
+	if run == nil {
+		return "", err
+	}
+
+	//TODO: should be in another pkg
+	msfQueries := GenerateMsfQueries(run.OpenPorts())
+
+	response, err := json.Marshal(NmapToolResult{NmapRun: run, MsfQueries: msfQueries})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal response: %w", err)
+	}
+	return string(response), nil
+}
+
+// Scan runs nmap against the IP and returns the parsed scan report.
+// If nmap fails or the context is done, the hosts scanned before are returned with the error set in NmapRun.Error,
+// the run is nil only if nothing was reported.
+func (s NmapTool) Scan(ctx context.Context, nmapToolArgs NmapToolArgs) (*NmapRun, error) {
 	if nmapToolArgs.IP == "" || net.ParseIP(nmapToolArgs.IP) == nil {
-		return "", fmt.Errorf("invalid or missing IP: %q", nmapToolArgs.IP)
+		return nil, fmt.Errorf("invalid or missing IP: %q", nmapToolArgs.IP)
 	}
 
 	var args []string
 	if len(nmapToolArgs.Args) > 0 {
-		args = append([]string{}, nmapToolArgs.Args...)
 		ipPresent := false
-		for _, a := range args {
+		for i := 0; i < len(nmapToolArgs.Args); i++ {
+			a := nmapToolArgs.Args[i]
+			if slices.Contains(nmapOutputArgs, a) {
+				// Skip the output file too
+				i++
+				continue
+			}
+			if slices.ContainsFunc(nmapOutputArgs, func(outputArg string) bool {
+				return strings.HasPrefix(a, outputArg)
+			}) {
+				continue
+			}
 			if a == nmapToolArgs.IP {
 				ipPresent = true
-				break
 			}
+			args = append(args, a)
 		}
 		if !ipPresent {
 			args = append(args, nmapToolArgs.IP)
@@ -86,40 +120,32 @@ func (s NmapTool) Call(ctx context.Context, input string) (string, error) {
 			nmapToolArgs.IP,
 		}
 	}
-
-	cmd := exec.Command("nmap", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to execute nmap: %w - output: %s", err, string(output))
+	args = append(args, "-oX", "-")
+
+	// Only the stdout is parsed, the warnings go to the stderr.
+	// It is parsed before checking the error, to keep the hosts scanned before the failure or the timeout.
+	output, err := commandContext(ctx, "nmap", args...).Output()
+	run, parseErr := ParseNmapXML(output)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		err = fmt.Errorf("failed to execute nmap: %w", ctx.Err())
+	case err != nil:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("failed to execute nmap %v: %w - output: %s", args, err, strings.TrimSpace(string(exitErr.Stderr)))
+		} else {
+			err = fmt.Errorf("failed to execute nmap %v: %w", args, err)
+		}
+	default:
+		err = parseErr
 	}
-
-	ports := ParseNmapPorts(string(output))
-
-	//TODO: should be in another pkg
-	msfQueries := GenerateMsfQueries(ports)
-
-	response := fmt.Sprintf("Used args: %v\n\nGenerated Metasploit queries: %v", args, msfQueries)
-	return response, nil
-}
-
-func ParseNmapPorts(nmapOutput string) []PortInfo {
-	var ports []PortInfo
-	re := regexp.MustCompile(`(\d+)\/tcp\s+(\w+)\s+(.+?)\s*(?:\n|$)`)
-	matches := re.FindAllStringSubmatch(nmapOutput, -1)
-
-	for _, match := range matches {
-		if len(match) >= 4 {
-
-			ports = append(ports, PortInfo{
-				Port:    match[1],
-				State:   strings.TrimSpace(match[2]),
-				Service: strings.TrimSpace(match[3]),
-			})
-			fmt.Println("found port:", match[1], "found state:", ports[len(ports)-1].State, "found service:", ports[len(ports)-1].Service)
+	if run != nil && err != nil {
+		if run.Error != "" {
+			run.Error += "; "
 		}
+		run.Error += err.Error()
 	}
-	fmt.Println("found ports:", ports)
-	return ports
+	return run, err
 }
 
 func init() {
@@ -130,8 +156,9 @@ func init() {
 			}
 
 			return &tools.ToolData{
-				Definition: NmapToolDefinition,
-				Call:       NmapTool{}.Call,
+				Definition:  NmapToolDefinition,
+				Call:        NmapTool{}.Call,
+				OutputLimit: cfg.ToolOutputLimit,
 			}, nil
 		},
 	)
@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NmapRun is the nmap scan report parsed from its XML output.
+type NmapRun struct {
+	Args    string     `json:"args,omitempty"`
+	Version string     `json:"version,omitempty"`
+	Hosts   []NmapHost `json:"hosts"`
+	// Summary is the scan statistics line like "1 IP address (1 host up) scanned in 3.21 seconds"
+	Summary string `json:"summary,omitempty"`
+	// Error is set if nmap exited with an error or the scan was interrupted, the hosts are the ones scanned before
+	Error string `json:"error,omitempty"`
+}
+
+type NmapHost struct {
+	Status     string           `json:"status"`
+	Reason     string           `json:"reason,omitempty"`
+	Addresses  []NmapAddress    `json:"addresses"`
+	Hostnames  []NmapHostname   `json:"hostnames,omitempty"`
+	Ports      []NmapPort       `json:"ports,omitempty"`
+	ExtraPorts []NmapExtraPorts `json:"extra_ports,omitempty"`
+	// Scripts are the host script results
+	Scripts   []NmapScript  `json:"scripts,omitempty"`
+	OSMatches []NmapOSMatch `json:"os_matches,omitempty"`
+}
+
+type NmapAddress struct {
+	Addr string `xml:"addr,attr" json:"addr"`
+	// Type is ipv4, ipv6 or mac
+	Type   string `xml:"addrtype,attr" json:"type"`
+	Vendor string `xml:"vendor,attr" json:"vendor,omitempty"`
+}
+
+type NmapHostname struct {
+	Name string `xml:"name,attr" json:"name"`
+	// Type is user for the scanned name or PTR for the reverse DNS one
+	Type string `xml:"type,attr" json:"type,omitempty"`
+}
+
+type NmapPort struct {
+	// Protocol is tcp, udp or sctp
+	Protocol  string       `json:"protocol"`
+	Port      int          `json:"port"`
+	State     string       `json:"state"`
+	Reason    string       `json:"reason,omitempty"`
+	Service   string       `json:"service,omitempty"`
+	Product   string       `json:"product,omitempty"`
+	Version   string       `json:"version,omitempty"`
+	ExtraInfo string       `json:"extra_info,omitempty"`
+	Tunnel    string       `json:"tunnel,omitempty"`
+	CPE       []string     `json:"cpe,omitempty"`
+	Scripts   []NmapScript `json:"scripts,omitempty"`
+}
+
+// NmapExtraPorts is the number of the ports in the same state not listed one by one, like 997 closed ones.
+type NmapExtraPorts struct {
+	State string `xml:"state,attr" json:"state"`
+	Count int    `xml:"count,attr" json:"count"`
+}
+
+type NmapScript struct {
+	ID     string `xml:"id,attr" json:"id"`
+	Output string `xml:"output,attr" json:"output"`
+}
+
+type NmapOSMatch struct {
+	Name     string   `json:"name"`
+	Accuracy int      `json:"accuracy"`
+	CPE      []string `json:"cpe,omitempty"`
+}
+
+// The nmap XML elements nested differently from the result types
+type nmapFinishedXML struct {
+	Summary  string `xml:"summary,attr"`
+	Exit     string `xml:"exit,attr"`
+	ErrorMsg string `xml:"errormsg,attr"`
+}
+
+type nmapHostXML struct {
+	Status struct {
+		State  string `xml:"state,attr"`
+		Reason string `xml:"reason,attr"`
+	} `xml:"status"`
+	Addresses  []NmapAddress    `xml:"address"`
+	Hostnames  []NmapHostname   `xml:"hostnames>hostname"`
+	Ports      []nmapPortXML    `xml:"ports>port"`
+	ExtraPorts []NmapExtraPorts `xml:"ports>extraports"`
+	Scripts    []NmapScript     `xml:"hostscript>script"`
+	OSMatches  []struct {
+		Name      string `xml:"name,attr"`
+		Accuracy  int    `xml:"accuracy,attr"`
+		OSClasses []struct {
+			CPE []string `xml:"cpe"`
+		} `xml:"osclass"`
+	} `xml:"os>osmatch"`
+}
+
+type nmapPortXML struct {
+	Protocol string `xml:"protocol,attr"`
+	Port     int    `xml:"portid,attr"`
+	State    struct {
+		State  string `xml:"state,attr"`
+		Reason string `xml:"reason,attr"`
+	} `xml:"state"`
+	Service struct {
+		Name      string   `xml:"name,attr"`
+		Product   string   `xml:"product,attr"`
+		Version   string   `xml:"version,attr"`
+		ExtraInfo string   `xml:"extrainfo,attr"`
+		Tunnel    string   `xml:"tunnel,attr"`
+		CPE       []string `xml:"cpe"`
+	} `xml:"service"`
+	Scripts []NmapScript `xml:"script"`
+}
+
+// ParseNmapXML parses the nmap -oX output. The hosts are decoded one by one, so the output cut
+// by the interrupted scan returns the hosts completed before the cut together with the parse error.
+func ParseNmapXML(data []byte) (*NmapRun, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var run *NmapRun
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return run, fmt.Errorf("parse nmap XML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case start.Name.Local == "nmaprun":
+			run = &NmapRun{Hosts: []NmapHost{}}
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "args":
+					run.Args = attr.Value
+				case "version":
+					run.Version = attr.Value
+				}
+			}
+		case run == nil:
+			continue
+		case start.Name.Local == "host":
+			hostXML := nmapHostXML{}
+			if err := decoder.DecodeElement(&hostXML, &start); err != nil {
+				return run, fmt.Errorf("parse nmap XML host: %w", err)
+			}
+			run.Hosts = append(run.Hosts, hostXML.host())
+		case start.Name.Local == "finished":
+			finished := nmapFinishedXML{}
+			if err := decoder.DecodeElement(&finished, &start); err != nil {
+				return run, fmt.Errorf("parse nmap XML: %w", err)
+			}
+			run.Summary = finished.Summary
+			if finished.Exit == "error" {
+				run.Error = finished.ErrorMsg
+			}
+		}
+	}
+	if run == nil {
+		return nil, fmt.Errorf("parse nmap XML: no nmaprun element")
+	}
+	return run, nil
+}
+
+func (hostXML nmapHostXML) host() NmapHost {
+	host := NmapHost{
+		Status:     hostXML.Status.State,
+		Reason:     hostXML.Status.Reason,
+		Addresses:  hostXML.Addresses,
+		Hostnames:  hostXML.Hostnames,
+		ExtraPorts: hostXML.ExtraPorts,
+		Scripts:    hostXML.Scripts,
+	}
+	for _, portXML := range hostXML.Ports {
+		host.Ports = append(host.Ports, NmapPort{
+			Protocol:  portXML.Protocol,
+			Port:      portXML.Port,
+			State:     portXML.State.State,
+			Reason:    portXML.State.Reason,
+			Service:   portXML.Service.Name,
+			Product:   portXML.Service.Product,
+			Version:   portXML.Service.Version,
+			ExtraInfo: portXML.Service.ExtraInfo,
+			Tunnel:    portXML.Service.Tunnel,
+			CPE:       portXML.Service.CPE,
+			Scripts:   portXML.Scripts,
+		})
+	}
+	for _, matchXML := range hostXML.OSMatches {
+		match := NmapOSMatch{Name: matchXML.Name, Accuracy: matchXML.Accuracy}
+		for _, class := range matchXML.OSClasses {
+			match.CPE = append(match.CPE, class.CPE...)
+		}
+		host.OSMatches = append(host.OSMatches, match)
+	}
+	return host
+}
+
+// OpenPorts returns the open ports of all the hosts.
+func (r *NmapRun) OpenPorts() []NmapPort {
+	var ports []NmapPort
+	for _, host := range r.Hosts {
+		for _, port := range host.Ports {
+			if port.State == "open" {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func readNmapFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseNmapXML(t *testing.T) {
+	tests := []struct {
+		fixture string
+		hosts   []string
+		wantErr bool
+		check   func(t *testing.T, run *NmapRun)
+	}{
+		{
+			fixture: "nmap_multihost.xml",
+			hosts:   []string{"192.168.1.10", "192.168.1.12", "192.168.1.11"},
+			check: func(t *testing.T, run *NmapRun) {
+				if run.Version != "7.94SVN" || !strings.Contains(run.Args, "-sU") || !strings.Contains(run.Summary, "2 hosts up") {
+					t.Errorf("run %q %q %q", run.Version, run.Args, run.Summary)
+				}
+				if run.Error != "" {
+					t.Errorf("error %q of the successful scan", run.Error)
+				}
+
+				host := run.Hosts[0]
+				if host.Status != "up" || len(host.Addresses) != 2 || host.Addresses[1].Vendor != "QEMU virtual NIC" {
+					t.Errorf("host %+v", host)
+				}
+				if len(host.Hostnames) != 1 || host.Hostnames[0].Name != "target.lan" {
+					t.Errorf("hostnames %+v", host.Hostnames)
+				}
+				if len(host.ExtraPorts) != 1 || host.ExtraPorts[0].Count != 997 {
+					t.Errorf("extra ports %+v", host.ExtraPorts)
+				}
+				if len(host.Scripts) != 1 || host.Scripts[0].ID != "nbstat" {
+					t.Errorf("host scripts %+v", host.Scripts)
+				}
+				if len(host.OSMatches) != 1 || host.OSMatches[0].Accuracy != 96 || len(host.OSMatches[0].CPE) != 2 {
+					t.Errorf("os matches %+v", host.OSMatches)
+				}
+
+				if len(host.Ports) != 4 {
+					t.Fatalf("ports %+v", host.Ports)
+				}
+				ssh := host.Ports[0]
+				if ssh.Product != "OpenSSH" || ssh.Version != "8.9p1 Ubuntu 3ubuntu0.10" || len(ssh.CPE) != 2 ||
+					len(ssh.Scripts) != 1 || !strings.Contains(ssh.Scripts[0].Output, "ED25519") {
+					t.Errorf("ssh port %+v", ssh)
+				}
+				if host.Ports[1].Tunnel != "ssl" {
+					t.Errorf("https port %+v", host.Ports[1])
+				}
+				if udp := host.Ports[3]; udp.Protocol != "udp" || udp.Port != 161 || udp.State != "open|filtered" {
+					t.Errorf("udp port %+v", udp)
+				}
+				if run.Hosts[2].Status != "down" || len(run.Hosts[2].Ports) != 0 {
+					t.Errorf("down host %+v", run.Hosts[2])
+				}
+			},
+		},
+		{
+			fixture: "nmap_error.xml",
+			hosts:   []string{},
+			check: func(t *testing.T, run *NmapRun) {
+				if !strings.Contains(run.Error, "requires root privileges") {
+					t.Errorf("error %q, want the nmap error message", run.Error)
+				}
+			},
+		},
+		{
+			fixture: "nmap_truncated.xml",
+			hosts:   []string{"192.168.1.10"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			run, err := ParseNmapXML(readNmapFixture(t, tt.fixture))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+			if run == nil {
+				t.Fatal("no run parsed")
+			}
+			var hosts []string
+			for _, host := range run.Hosts {
+				hosts = append(hosts, host.Addresses[0].Addr)
+			}
+			if !slices.Equal(hosts, tt.hosts) {
+				t.Errorf("hosts %v, want %v", hosts, tt.hosts)
+			}
+			if tt.check != nil {
+				tt.check(t, run)
+			}
+		})
+	}
+
+	if _, err := ParseNmapXML([]byte("Starting Nmap")); err == nil {
+		t.Errorf("parsed the output without the XML report")
+	}
+}
+
+func TestOpenPorts(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{"nmap_multihost.xml", []string{"tcp/22", "tcp/443", "udp/53", "tcp/80"}},
+		{"nmap_error.xml", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			run, err := ParseNmapXML(readNmapFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, port := range run.OpenPorts() {
+				got = append(got, port.Protocol+"/"+strconv.Itoa(port.Port))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("open ports %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateMsfQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []NmapPort
+		want  []string
+	}{
+		{"no ports", nil, nil},
+		{
+			"service and product",
+			[]NmapPort{{Port: 22, State: "open", Service: "ssh", Product: "OpenSSH"}},
+			[]string{"type:exploit name:ssh", "type:exploit OpenSSH", "port:22"},
+		},
+		{
+			"unknown service",
+			[]NmapPort{{Port: 9999, State: "open"}},
+			[]string{"port:9999"},
+		},
+		{
+			"not open",
+			[]NmapPort{{Port: 23, State: "closed", Service: "telnet"}, {Port: 161, State: "open|filtered", Service: "snmp"}},
+			nil,
+		},
+		{
+			"same service on the hosts",
+			[]NmapPort{
+				{Port: 80, State: "open", Service: "http", Product: "nginx"},
+				{Port: 80, State: "open", Service: "http", Product: "Apache httpd"},
+			},
+			[]string{"type:exploit name:http", "type:exploit nginx", "port:80", "type:exploit Apache httpd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateMsfQueries(tt.ports); !slices.Equal(got, tt.want) {
+				t.Errorf("queries %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
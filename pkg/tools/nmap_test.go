@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNmap puts the nmap script printing the fixture and running the command after it first in PATH.
+func fakeNmap(t *testing.T, fixture, then string) {
+	t.Helper()
+	dir := t.TempDir()
+	path, err := filepath.Abs(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncat " + shellQuote(path) + "\necho 'nmap failed' >&2\n" + then + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "nmap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestNmapScanPartial(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		then    string
+		timeout time.Duration
+		wantErr string
+	}{
+		{"exit error", "nmap_truncated.xml", "exit 1", time.Minute, "nmap failed"},
+		{"timeout", "nmap_truncated.xml", "exec sleep 60", 500 * time.Millisecond, "deadline exceeded"},
+		{"xml error", "nmap_error.xml", "exit 1", time.Minute, "requires root privileges"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeNmap(t, tt.fixture, tt.then)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			output, err := NmapTool{}.Call(ctx, `{"ip": "192.168.1.10"}`)
+			if err != nil {
+				t.Fatalf("call failed instead of returning the partial results: %v", err)
+			}
+			result := NmapToolResult{}
+			if err := json.Unmarshal([]byte(output), &result); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(result.Error, tt.wantErr) {
+				t.Errorf("error %q, want %q", result.Error, tt.wantErr)
+			}
+			if tt.fixture == "nmap_truncated.xml" && (len(result.Hosts) != 1 || len(result.MsfQueries) == 0) {
+				t.Errorf("hosts %+v, queries %v, want the host scanned before the failure", result.Hosts, result.MsfQueries)
+			}
+		})
+	}
+
+	t.Run("no output", func(t *testing.T) {
+		fakeNmap(t, "missing.xml", "exit 1")
+		if _, err := (NmapTool{}).Call(context.Background(), `{"ip": "192.168.1.10"}`); err == nil {
+			t.Errorf("call succeeded without the scan report")
+		}
+	})
+}
@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pgvectorgo "github.com/pgvector/pgvector-go"
+	"github.com/rs/zerolog/log"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores/pgvector"
+)
+
+// PGVectorStore keeps the collections in Postgres with pgvector, sharing the connection pool
+// and the embedder between them.
+// The pool and the embedder are created on the first use, the stores are kept per collection until Close.
+// It is safe for the concurrent use.
+type PGVectorStore struct {
+	OpenAIURL      string
+	OpenAIToken    string
+	DBConnection   string
+	EmbeddingModel string
+
+	mu       sync.Mutex
+	pool     *pgxpool.Pool
+	embedder embeddings.Embedder
+	stores   map[string]pgvector.Store
+
+	textIndexed bool
+}
+
+// Collection returns the collection store, creating the collection if it does not exist.
+// The searches and the collection queries do not use it, so only AddDocuments creates the collections.
+func (s *PGVectorStore) Collection(ctx context.Context, collection string) (pgvector.Store, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if store, ok := s.stores[collection]; ok {
+		return store, nil
+	}
+	if err := s.connect(ctx); err != nil {
+		return pgvector.Store{}, err
+	}
+
+	// pgvector overwrites the collection metadata on the store creation, keep the existing one
+	var metadata map[string]any
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		`SELECT cmetadata FROM %s WHERE name = $1`, pgvector.DefaultCollectionStoreTableName,
+	), collection).Scan(&metadata)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) && !isUndefinedTable(err) {
+		return pgvector.Store{}, fmt.Errorf("query collection metadata: %w", err)
+	}
+
+	// The store is not closed on its own, since it would close the shared pool
+	store, err := pgvector.New(
+		ctx,
+		pgvector.WithCollectionName(collection),
+		pgvector.WithCollectionMetadata(metadata),
+		pgvector.WithConn(s.pool),
+		pgvector.WithEmbedder(s.embedder),
+	)
+	if err != nil {
+		return pgvector.Store{}, err
+	}
+	s.createTextIndex(ctx)
+	if s.stores == nil {
+		s.stores = map[string]pgvector.Store{}
+	}
+	s.stores[collection] = store
+	return store, nil
+}
+
+// Pool returns the connection pool for the queries not covered by the store.
+func (s *PGVectorStore) Pool(ctx context.Context) (*pgxpool.Pool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.connect(ctx); err != nil {
+		return nil, err
+	}
+	return s.pool, nil
+}
+
+// connection returns the pool and the embedder.
+func (s *PGVectorStore) connection(ctx context.Context) (*pgxpool.Pool, embeddings.Embedder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.connect(ctx); err != nil {
+		return nil, nil, err
+	}
+	return s.pool, s.embedder, nil
+}
+
+// connect creates the pool and the embedder if needed, called with mu held.
+func (s *PGVectorStore) connect(ctx context.Context) error {
+	if s.pool == nil {
+		config, err := pgxpool.ParseConfig(s.DBConnection)
+		if err != nil {
+			return err
+		}
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			return err
+		}
+		s.pool = pool
+	}
+
+	if s.embedder == nil {
+		e, err := newEmbedder(s.OpenAIURL, s.OpenAIToken, s.EmbeddingModel)
+		if err != nil {
+			return err
+		}
+		s.embedder = e
+	}
+	return nil
+}
+
+func (s *PGVectorStore) AddDocuments(ctx context.Context, collection string, docs []schema.Document) error {
+	store, err := s.Collection(ctx, collection)
+	if err != nil {
+		return err
+	}
+	_, err = store.AddDocuments(ctx, docs)
+	return err
+}
+
+// SimilaritySearch ranks the documents by the cosine similarity to the query embedding.
+// It only reads the tables, so searching the missing collection does not create it.
+func (s *PGVectorStore) SimilaritySearch(ctx context.Context, collection, query string, filter SemanticFilter, minScore float32, limit int) ([]SemanticResult, error) {
+	pool, embedder, err := s.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vector, err := embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, fmt.Sprintf(
+		`SELECT e.document, e.cmetadata, 1 - (e.embedding <=> $2) AS score
+FROM %[1]s e JOIN %[2]s c ON e.collection_id = c.uuid
+WHERE c.name = $1 AND vector_dims(e.embedding) = $3
+	AND ($4::real <= 0 OR 1 - (e.embedding <=> $2) >= $4)
+	AND ($5::text = '' OR e.cmetadata->>'%[3]s' = $5)
+	AND ($6::text = '' OR starts_with(e.cmetadata->>'%[4]s', $6))
+ORDER BY e.embedding <=> $2
+LIMIT $7`,
+		pgvector.DefaultEmbeddingStoreTableName, pgvector.DefaultCollectionStoreTableName,
+		IndexMetadataLanguage, IndexMetadataPath,
+	), collection, pgvectorgo.NewVector(vector), len(vector), minScore, filter.Language, filter.PathPrefix, limit)
+	if isUndefinedTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("similarity search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SemanticResult
+	for rows.Next() {
+		var content string
+		var metadata map[string]any
+		var score float32
+		if err := rows.Scan(&content, &metadata, &score); err != nil {
+			return nil, err
+		}
+		result := semanticResultFromMetadata(content, metadata)
+		result.Score = score
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// LexicalSearch matches the query terms with the Postgres full text search, ranked by the terms density.
+// Any of the terms matches, the 'simple' configuration keeps the identifiers and the error strings
+// as is instead of stemming them.
+func (s *PGVectorStore) LexicalSearch(ctx context.Context, collection, query string, filter SemanticFilter, limit int) ([]SemanticResult, error) {
+	s.mu.Lock()
+	err := s.connect(ctx)
+	if err == nil {
+		s.createTextIndex(ctx)
+	}
+	pool := s.pool
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, fmt.Sprintf(
+		`SELECT e.document, e.cmetadata, ts_rank_cd(to_tsvector('simple', e.document), q) AS score
+FROM %[1]s e JOIN %[2]s c ON e.collection_id = c.uuid,
+	to_tsquery('simple', replace(plainto_tsquery('simple', $2)::text, ' & ', ' | ')) q
+WHERE c.name = $1 AND to_tsvector('simple', e.document) @@ q
+	AND ($3::text = '' OR e.cmetadata->>'%[3]s' = $3)
+	AND ($4::text = '' OR starts_with(e.cmetadata->>'%[4]s', $4))
+ORDER BY score DESC
+LIMIT $5`,
+		pgvector.DefaultEmbeddingStoreTableName, pgvector.DefaultCollectionStoreTableName,
+		IndexMetadataLanguage, IndexMetadataPath,
+	), collection, query, filter.Language, filter.PathPrefix, limit)
+	if isUndefinedTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lexical search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SemanticResult
+	for rows.Next() {
+		var content string
+		var metadata map[string]any
+		var score float32
+		if err := rows.Scan(&content, &metadata, &score); err != nil {
+			return nil, err
+		}
+		result := semanticResultFromMetadata(content, metadata)
+		result.Score = score
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *PGVectorStore) IndexedFiles(ctx context.Context, collection string) (map[string]string, error) {
+	pool, err := s.Pool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(
+		`SELECT e.cmetadata->>'%[3]s', e.cmetadata->>'%[4]s'
+FROM %[1]s e JOIN %[2]s c ON e.collection_id = c.uuid
+WHERE c.name = $1 AND e.cmetadata->>'%[3]s' IS NOT NULL
+GROUP BY 1, 2`,
+		pgvector.DefaultEmbeddingStoreTableName, pgvector.DefaultCollectionStoreTableName,
+		IndexMetadataPath, IndexMetadataHash,
+	), collection)
+	if isUndefinedTable(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query indexed files: %w", err)
+	}
+	defer rows.Close()
+
+	indexed := map[string]string{}
+	for rows.Next() {
+		var rel string
+		var hash *string
+		if err := rows.Scan(&rel, &hash); err != nil {
+			return nil, err
+		}
+		addIndexedFile(indexed, rel, hash)
+	}
+	return indexed, rows.Err()
+}
+
+func (s *PGVectorStore) RemoveFiles(ctx context.Context, collection string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	pool, err := s.Pool(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = pool.Exec(ctx, fmt.Sprintf(
+		`DELETE FROM %[1]s e USING %[2]s c
+WHERE e.collection_id = c.uuid AND c.name = $1 AND e.cmetadata->>'%[3]s' = ANY($2)`,
+		pgvector.DefaultEmbeddingStoreTableName, pgvector.DefaultCollectionStoreTableName,
+		IndexMetadataPath,
+	), collection, paths)
+	if err != nil && !isUndefinedTable(err) {
+		return fmt.Errorf("remove indexed files: %w", err)
+	}
+	return nil
+}
+
+func (s *PGVectorStore) Collections(ctx context.Context) ([]CollectionInfo, error) {
+	pool, err := s.Pool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(
+		`SELECT c.name, coalesce(c.cmetadata->>'description', ''), count(e.uuid)
+FROM %[2]s c LEFT JOIN %[1]s e ON e.collection_id = c.uuid
+GROUP BY c.uuid
+ORDER BY c.name`,
+		pgvector.DefaultEmbeddingStoreTableName, pgvector.DefaultCollectionStoreTableName,
+	))
+	if isUndefinedTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query collections: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []CollectionInfo
+	for rows.Next() {
+		info := CollectionInfo{}
+		if err := rows.Scan(&info.Name, &info.Description, &info.Documents); err != nil {
+			return nil, err
+		}
+		collections = append(collections, info)
+	}
+	if err := rows.Err(); err != nil && !isUndefinedTable(err) {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// SetDescription updates the description of the existing collection, failing if it is missing.
+func (s *PGVectorStore) SetDescription(ctx context.Context, collection, description string) error {
+	pool, err := s.Pool(ctx)
+	if err != nil {
+		return err
+	}
+	tag, err := pool.Exec(ctx, fmt.Sprintf(
+		`UPDATE %s SET cmetadata = (coalesce(cmetadata::jsonb, '{}') || jsonb_build_object('description', $2::text))::json
+WHERE name = $1`,
+		pgvector.DefaultCollectionStoreTableName,
+	), collection, description)
+	if err != nil && !isUndefinedTable(err) {
+		return fmt.Errorf("set collection description: %w", err)
+	}
+	if err != nil || tag.RowsAffected() == 0 {
+		return fmt.Errorf("collection %q does not exist", collection)
+	}
+	return nil
+}
+
+// createTextIndex adds the full text index once the embedding table exists, called with mu held.
+// The expression index keeps the lexical search fast without changing the pgvector tables.
+func (s *PGVectorStore) createTextIndex(ctx context.Context) {
+	if s.textIndexed {
+		return
+	}
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %[1]s_document_tsv ON %[1]s USING gin (to_tsvector('simple', document))`,
+		pgvector.DefaultEmbeddingStoreTableName,
+	))
+	if isUndefinedTable(err) {
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("semantic store full text index creation")
+	}
+	s.textIndexed = true
+}
+
+// isUndefinedTable reports the query of the tables not created yet by pgvector.
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "42P01"
+}
+
+// Flush does nothing, the changes are written by every call.
+func (s *PGVectorStore) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close drops the collection stores and closes the connection pool.
+func (s *PGVectorStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stores = nil
+	if s.pool != nil {
+		s.pool.Close()
+		s.pool = nil
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Time to wait for the output pipes to close after the process group is killed
+const processWaitDelay = time.Second
+
+// commandContext returns the command started in its own process group.
+// On the context cancellation the whole group is killed, so no scans or browsers spawned by it are left running.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return err
+	}
+	// Do not wait forever for the output of the orphaned processes holding the pipes
+	cmd.WaitDelay = processWaitDelay
+	return cmd
+}
+
+// runCommand runs the command, returning its combined output.
+// The context error is returned instead of the kill signal if the command was cancelled.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := commandContext(ctx, name, args...).CombinedOutput()
+	if err != nil && ctx.Err() != nil {
+		return output, fmt.Errorf("%s: %w", name, ctx.Err())
+	}
+	return output, err
+}
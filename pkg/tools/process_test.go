@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processAlive reports if the process is running, the zombies waiting to be reaped are not.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunCommandCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		// command writes the pid of the process expected to be killed to the file
+		command func(pidFile string) []string
+	}{
+		{"child", func(pidFile string) []string {
+			return []string{"bash", "-c", "echo $$ > " + shellQuote(pidFile) + "; exec sleep 60"}
+		}},
+		// The grandchild holds the output pipe, it is killed with the process group
+		{"grandchild", func(pidFile string) []string {
+			return []string{"bash", "-c", "sleep 60 & echo $! > " + shellQuote(pidFile) + "; wait"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "pid")
+			command := tt.command(pidFile)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := runCommand(ctx, command[0], command[1:]...)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error %v, want the context deadline", err)
+			}
+			if elapsed > 200*time.Millisecond+processWaitDelay {
+				t.Errorf("returned after %s, want within the wait delay %s", elapsed, processWaitDelay)
+			}
+
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The killed orphan may take a moment to be reaped
+			deadline := time.Now().Add(time.Second)
+			for processAlive(pid) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if processAlive(pid) {
+				t.Errorf("grandchild %d is still running", pid)
+			}
+		})
+	}
+}
+
+func TestCommandContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := commandContext(ctx, "bash", "-c", "sleep 60 & sleep 60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	cmd.Wait()
+	if elapsed := time.Since(start); elapsed > processWaitDelay {
+		t.Errorf("wait returned after %s, want within the wait delay %s", elapsed, processWaitDelay)
+	}
+}
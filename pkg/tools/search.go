@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultSearchTimeout is the timeout of the search provider requests.
+const DefaultSearchTimeout = 15 * time.Second
+
+// Search providers names.
+const (
+	SearchProviderDuckDuckGo = "duckduckgo"
+	SearchProviderSearxNG    = "searxng"
+	SearchProviderBrave      = "brave"
+	SearchProviderBing       = "bing"
+	SearchProviderGoogle     = "google"
+)
+
+var (
+	ErrSearchRateLimited = errors.New("search rate limited")
+	ErrNoSearchProvider  = errors.New("no search provider")
+)
+
+// SearchResult is the single web search result, ranked from 1.
+type SearchResult struct {
+	Rank     int    `json:"rank"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Snippet  string `json:"snippet"`
+	Provider string `json:"provider"`
+}
+
+// SearchProvider is the web search backend.
+type SearchProvider interface {
+	Name() string
+	// Search returns at most maxResults results ranked by the provider.
+	Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error)
+}
+
+// FallbackSearch queries the providers in order, falling back to the next one on error or rate limit.
+type FallbackSearch struct {
+	Providers []SearchProvider
+}
+
+func (f FallbackSearch) Name() string {
+	names := make([]string, len(f.Providers))
+	for i, provider := range f.Providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (f FallbackSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if len(f.Providers) == 0 {
+		return nil, ErrNoSearchProvider
+	}
+
+	var errs []error
+	for _, provider := range f.Providers {
+		results, err := provider.Search(ctx, query, maxResults)
+		if err == nil {
+			return results, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Warn().Err(err).Msgf("search provider %s failed, trying the next one", provider.Name())
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// RenderSearchResults formats the results for the model, the same way for every provider.
+func RenderSearchResults(results []SearchResult) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+
+	var sb strings.Builder
+	for _, result := range results {
+		fmt.Fprintf(&sb, "%d. %s\nURL: %s\n", result.Rank, result.Title, result.URL)
+		if result.Snippet != "" {
+			fmt.Fprintf(&sb, "%s\n", result.Snippet)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// rankResults numbers the results and drops the ones without URL, keeping at most maxResults.
+func rankResults(provider string, results []SearchResult, maxResults int) []SearchResult {
+	ranked := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if result.URL == "" {
+			continue
+		}
+		if maxResults > 0 && len(ranked) == maxResults {
+			break
+		}
+		result.Rank = len(ranked) + 1
+		result.Provider = provider
+		result.Title = normalizeSpace(result.Title)
+		result.Snippet = normalizeSpace(result.Snippet)
+		ranked = append(ranked, result)
+	}
+	return ranked
+}
+
+// doSearchRequest sends the request, decoding the JSON response into out if it is not nil.
+// The response body is returned for the HTML providers.
+func doSearchRequest(client *http.Client, req *http.Request, out any) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSearchTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	switch {
+	// DuckDuckGo answers the banned clients with 202 and the captcha page
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusAccepted:
+		return nil, fmt.Errorf("%w: %s", ErrSearchRateLimited, resp.Status)
+	// Google answers the exhausted quota with 403 and the reason in the error body
+	case resp.StatusCode == http.StatusForbidden && quotaExceeded(body):
+		return nil, fmt.Errorf("%w: %s quota exceeded", ErrSearchRateLimited, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return body, nil
+}
+
+// quotaExceeded reports if the Google API error body has the quota or rate limit reason.
+func quotaExceeded(body []byte) bool {
+	response := struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false
+	}
+	for _, e := range response.Error.Errors {
+		switch e.Reason {
+		case "quotaExceeded", "rateLimitExceeded", "userRateLimitExceeded", "dailyLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,282 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DuckDuckGoSearch scrapes the DuckDuckGo HTML results page, no API key is needed.
+type DuckDuckGoSearch struct {
+	// BaseURL is https://html.duckduckgo.com/html/ if empty
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+}
+
+func (s DuckDuckGoSearch) Name() string {
+	return SearchProviderDuckDuckGo
+}
+
+func (s DuckDuckGoSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	req, err := newSearchRequest(ctx, s.BaseURL, "https://html.duckduckgo.com/html/", url.Values{"q": {query}})
+	if err != nil {
+		return nil, err
+	}
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+
+	body, err := doSearchRequest(s.Client, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse results page: %w", err)
+	}
+	// The anomaly page with the captcha is returned instead of the results when the client is banned
+	if doc.Find(".anomaly-modal, #challenge-form").Length() > 0 {
+		return nil, fmt.Errorf("%w: captcha requested", ErrSearchRateLimited)
+	}
+
+	var results []SearchResult
+	doc.Find(".web-result").Each(func(_ int, node *goquery.Selection) {
+		link := node.Find(".result__a").First()
+		results = append(results, SearchResult{
+			Title:   link.Text(),
+			URL:     duckDuckGoTarget(link.AttrOr("href", "")),
+			Snippet: node.Find(".result__snippet").Text(),
+		})
+	})
+	return rankResults(s.Name(), results, maxResults), nil
+}
+
+// duckDuckGoTarget returns the result URL from the DuckDuckGo redirect link.
+func duckDuckGoTarget(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if target := u.Query().Get("uddg"); target != "" {
+		return target
+	}
+	if u.Scheme == "" && strings.HasPrefix(href, "//") {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// SearxNGSearch queries the self-hosted SearxNG instance JSON API, the json format must be enabled in its settings.
+type SearxNGSearch struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s SearxNGSearch) Name() string {
+	return SearchProviderSearxNG
+}
+
+func (s SearxNGSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if s.BaseURL == "" {
+		return nil, fmt.Errorf("empty SearxNG URL")
+	}
+	req, err := newSearchRequest(ctx, strings.TrimSuffix(s.BaseURL, "/")+"/search", "", url.Values{
+		"q":      {query},
+		"format": {"json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}{}
+	if _, err := doSearchRequest(s.Client, req, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(response.Results))
+	for i, result := range response.Results {
+		results[i] = SearchResult{Title: result.Title, URL: result.URL, Snippet: result.Content}
+	}
+	return rankResults(s.Name(), results, maxResults), nil
+}
+
+// BraveSearch queries the Brave Search web API.
+type BraveSearch struct {
+	APIKey string
+	// BaseURL is https://api.search.brave.com/res/v1/web/search if empty
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s BraveSearch) Name() string {
+	return SearchProviderBrave
+}
+
+func (s BraveSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if s.APIKey == "" {
+		return nil, fmt.Errorf("empty Brave API key")
+	}
+	params := url.Values{"q": {query}}
+	if maxResults > 0 {
+		params.Set("count", strconv.Itoa(min(maxResults, 20)))
+	}
+	req, err := newSearchRequest(ctx, s.BaseURL, "https://api.search.brave.com/res/v1/web/search", params)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", s.APIKey)
+
+	response := struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}{}
+	if _, err := doSearchRequest(s.Client, req, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(response.Web.Results))
+	for i, result := range response.Web.Results {
+		results[i] = SearchResult{Title: result.Title, URL: result.URL, Snippet: stripTags(result.Description)}
+	}
+	return rankResults(s.Name(), results, maxResults), nil
+}
+
+// BingSearch queries the Bing Web Search API or a compatible REST API.
+type BingSearch struct {
+	APIKey string
+	// BaseURL is https://api.bing.microsoft.com/v7.0/search if empty
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s BingSearch) Name() string {
+	return SearchProviderBing
+}
+
+func (s BingSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if s.APIKey == "" {
+		return nil, fmt.Errorf("empty Bing API key")
+	}
+	params := url.Values{"q": {query}}
+	if maxResults > 0 {
+		params.Set("count", strconv.Itoa(min(maxResults, 50)))
+	}
+	req, err := newSearchRequest(ctx, s.BaseURL, "https://api.bing.microsoft.com/v7.0/search", params)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", s.APIKey)
+
+	response := struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}{}
+	if _, err := doSearchRequest(s.Client, req, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(response.WebPages.Value))
+	for i, result := range response.WebPages.Value {
+		results[i] = SearchResult{Title: result.Name, URL: result.URL, Snippet: result.Snippet}
+	}
+	return rankResults(s.Name(), results, maxResults), nil
+}
+
+// GoogleSearch queries the Google Programmable Search Engine (Custom Search JSON API).
+type GoogleSearch struct {
+	APIKey string
+	// EngineID is the programmable search engine ID, the cx parameter
+	EngineID string
+	// BaseURL is https://www.googleapis.com/customsearch/v1 if empty
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s GoogleSearch) Name() string {
+	return SearchProviderGoogle
+}
+
+func (s GoogleSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if s.APIKey == "" || s.EngineID == "" {
+		return nil, fmt.Errorf("empty Google API key or search engine ID")
+	}
+	params := url.Values{
+		"key": {s.APIKey},
+		"cx":  {s.EngineID},
+		"q":   {query},
+	}
+	if maxResults > 0 {
+		// The API returns at most 10 results per request
+		params.Set("num", strconv.Itoa(min(maxResults, 10)))
+	}
+	req, err := newSearchRequest(ctx, s.BaseURL, "https://www.googleapis.com/customsearch/v1", params)
+	if err != nil {
+		return nil, err
+	}
+
+	response := struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+	}{}
+	if _, err := doSearchRequest(s.Client, req, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(response.Items))
+	for i, result := range response.Items {
+		results[i] = SearchResult{Title: result.Title, URL: result.Link, Snippet: result.Snippet}
+	}
+	return rankResults(s.Name(), results, maxResults), nil
+}
+
+func newSearchRequest(ctx context.Context, baseURL, defaultURL string, params url.Values) (*http.Request, error) {
+	if baseURL == "" {
+		baseURL = defaultURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("search URL: %w", err)
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// stripTags removes the highlighting markup from the snippets.
+func stripTags(s string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return doc.Text()
+}
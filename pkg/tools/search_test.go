@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const duckDuckGoPage = `<html><body>
+<div class="result web-result">
+  <a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc%2F&amp;rut=x">The Go
+    Documentation</a>
+  <a class="result__snippet">Learn   Go.</a>
+</div>
+<div class="result web-result"><a class="result__a">No link</a></div>
+<div class="result web-result">
+  <a class="result__a" href="https://pkg.go.dev/">Packages</a>
+  <a class="result__snippet">Package index</a>
+</div>
+</body></html>`
+
+func TestSearchProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		// body is the response of the provider
+		body string
+		// check validates the provider request
+		check    func(r *http.Request) error
+		provider func(baseURL string) SearchProvider
+	}{
+		{
+			name: SearchProviderDuckDuckGo,
+			body: duckDuckGoPage,
+			check: func(r *http.Request) error {
+				if r.Header.Get("User-Agent") != "test-agent" {
+					return fmt.Errorf("user agent %q", r.Header.Get("User-Agent"))
+				}
+				return nil
+			},
+			provider: func(baseURL string) SearchProvider {
+				return DuckDuckGoSearch{BaseURL: baseURL, UserAgent: "test-agent"}
+			},
+		},
+		{
+			name: SearchProviderSearxNG,
+			body: `{"results": [
+				{"title": "The Go Documentation", "url": "https://go.dev/doc/", "content": "Learn Go."},
+				{"title": "No link", "url": ""},
+				{"title": "Packages", "url": "https://pkg.go.dev/", "content": "Package index"}
+			]}`,
+			check: func(r *http.Request) error {
+				if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+					return fmt.Errorf("request %s", r.URL)
+				}
+				return nil
+			},
+			provider: func(baseURL string) SearchProvider {
+				return SearxNGSearch{BaseURL: baseURL + "/"}
+			},
+		},
+		{
+			name: SearchProviderBrave,
+			body: `{"web": {"results": [
+				{"title": "The Go Documentation", "url": "https://go.dev/doc/", "description": "Learn <strong>Go</strong>."},
+				{"title": "No link", "url": ""},
+				{"title": "Packages", "url": "https://pkg.go.dev/", "description": "Package index"}
+			]}}`,
+			check: func(r *http.Request) error {
+				if r.Header.Get("X-Subscription-Token") != "key" || r.URL.Query().Get("count") != "2" {
+					return fmt.Errorf("request %s, token %q", r.URL, r.Header.Get("X-Subscription-Token"))
+				}
+				return nil
+			},
+			provider: func(baseURL string) SearchProvider {
+				return BraveSearch{APIKey: "key", BaseURL: baseURL}
+			},
+		},
+		{
+			name: SearchProviderBing,
+			body: `{"webPages": {"value": [
+				{"name": "The Go Documentation", "url": "https://go.dev/doc/", "snippet": "Learn Go."},
+				{"name": "No link", "url": ""},
+				{"name": "Packages", "url": "https://pkg.go.dev/", "snippet": "Package index"}
+			]}}`,
+			check: func(r *http.Request) error {
+				if r.Header.Get("Ocp-Apim-Subscription-Key") != "key" || r.URL.Query().Get("count") != "2" {
+					return fmt.Errorf("request %s, key %q", r.URL, r.Header.Get("Ocp-Apim-Subscription-Key"))
+				}
+				return nil
+			},
+			provider: func(baseURL string) SearchProvider {
+				return BingSearch{APIKey: "key", BaseURL: baseURL}
+			},
+		},
+		{
+			name: SearchProviderGoogle,
+			body: `{"items": [
+				{"title": "The Go Documentation", "link": "https://go.dev/doc/", "snippet": "Learn Go."},
+				{"title": "No link", "link": ""},
+				{"title": "Packages", "link": "https://pkg.go.dev/", "snippet": "Package index"}
+			]}`,
+			check: func(r *http.Request) error {
+				query := r.URL.Query()
+				if query.Get("key") != "key" || query.Get("cx") != "engine" || query.Get("num") != "2" {
+					return fmt.Errorf("request %s", r.URL)
+				}
+				return nil
+			},
+			provider: func(baseURL string) SearchProvider {
+				return GoogleSearch{APIKey: "key", EngineID: "engine", BaseURL: baseURL}
+			},
+		},
+	}
+
+	want := []SearchResult{
+		{Rank: 1, Title: "The Go Documentation", URL: "https://go.dev/doc/", Snippet: "Learn Go."},
+		{Rank: 2, Title: "Packages", URL: "https://pkg.go.dev/", Snippet: "Package index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("q") != "golang docs" {
+					t.Errorf("query %q", r.URL.Query().Get("q"))
+				}
+				if err := tt.check(r); err != nil {
+					t.Error(err)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			provider := tt.provider(server.URL)
+			results, err := provider.Search(context.Background(), "golang docs", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(want) {
+				t.Fatalf("results %+v, want %+v", results, want)
+			}
+			for i := range want {
+				expected := want[i]
+				expected.Provider = tt.name
+				if results[i] != expected {
+					t.Errorf("result %d %+v, want %+v", i, results[i], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		rateLimited bool
+	}{
+		{"too many requests", http.StatusTooManyRequests, "", true},
+		{"duckduckgo ban", http.StatusAccepted, "", true},
+		{"duckduckgo captcha", http.StatusOK, `<div class="anomaly-modal">Select all squares</div>`, true},
+		{"google quota", http.StatusForbidden, `{"error": {"code": 403, "errors": [{"reason": "quotaExceeded"}]}}`, true},
+		{"google rate limit", http.StatusForbidden, `{"error": {"code": 403, "errors": [{"reason": "rateLimitExceeded"}]}}`, true},
+		{"forbidden", http.StatusForbidden, `{"error": {"code": 403, "errors": [{"reason": "forbidden"}]}}`, false},
+		{"server error", http.StatusInternalServerError, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := DuckDuckGoSearch{BaseURL: server.URL}.Search(context.Background(), "query", 5)
+			if err == nil {
+				t.Fatal("search succeeded, want an error")
+			}
+			if got := errors.Is(err, ErrSearchRateLimited); got != tt.rateLimited {
+				t.Errorf("error %v, rate limited %t, want %t", err, got, tt.rateLimited)
+			}
+		})
+	}
+}
+
+// stubSearch returns the fixed results or error.
+type stubSearch struct {
+	name    string
+	results []SearchResult
+	err     error
+	calls   *int
+}
+
+func (s stubSearch) Name() string {
+	return s.name
+}
+
+func (s stubSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	if s.calls != nil {
+		*s.calls++
+	}
+	return s.results, s.err
+}
+
+func TestFallbackSearch(t *testing.T) {
+	found := []SearchResult{{Rank: 1, URL: "https://go.dev/"}}
+	limited := fmt.Errorf("%w: 429", ErrSearchRateLimited)
+
+	t.Run("next provider", func(t *testing.T) {
+		calls := 0
+		fallback := FallbackSearch{Providers: []SearchProvider{
+			stubSearch{name: "a", err: limited},
+			stubSearch{name: "b", results: found},
+			stubSearch{name: "c", calls: &calls},
+		}}
+		if fallback.Name() != "a,b,c" {
+			t.Errorf("name %q", fallback.Name())
+		}
+		results, err := fallback.Search(context.Background(), "query", 5)
+		if err != nil || len(results) != 1 {
+			t.Errorf("results %v, error %v, want the second provider results", results, err)
+		}
+		if calls != 0 {
+			t.Errorf("provider after the successful one was called")
+		}
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		fallback := FallbackSearch{Providers: []SearchProvider{
+			stubSearch{name: "a", err: limited},
+			stubSearch{name: "b", err: errors.New("status code error: 500")},
+		}}
+		_, err := fallback.Search(context.Background(), "query", 5)
+		if !errors.Is(err, ErrSearchRateLimited) || !strings.Contains(err.Error(), "b: status code error") {
+			t.Errorf("error %v, want the errors of every provider", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls := 0
+		fallback := FallbackSearch{Providers: []SearchProvider{
+			stubSearch{name: "a", err: context.Canceled},
+			stubSearch{name: "b", calls: &calls},
+		}}
+		if _, err := fallback.Search(ctx, "query", 5); !errors.Is(err, context.Canceled) {
+			t.Errorf("error %v, want the cancellation", err)
+		}
+		if calls != 0 {
+			t.Errorf("provider called after the cancellation")
+		}
+	})
+
+	t.Run("no providers", func(t *testing.T) {
+		if _, err := (FallbackSearch{}).Search(context.Background(), "query", 5); !errors.Is(err, ErrNoSearchProvider) {
+			t.Errorf("error %v, want %v", err, ErrNoSearchProvider)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+var ListCollectionsDefinition = llms.FunctionDefinition{
+	Name:        "listCollections",
+	Description: "Lists the semantic search collections with their document counts and descriptions. Use it to find the collection argument of semanticSearch.",
+	Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	},
+}
+
+// ListCollectionsTool reports the collections of the vector store, only the allowed ones if Collections is set.
+type ListCollectionsTool struct {
+	Store       VectorStore
+	Collections []string
+}
+
+func (t ListCollectionsTool) Call(ctx context.Context, input string) (string, error) {
+	collections, err := allowedCollections(ctx, t.Store, t.Collections)
+	if err != nil {
+		return "", err
+	}
+	if len(collections) == 0 {
+		return "No collections found.", nil
+	}
+
+	var sb strings.Builder
+	for _, collection := range collections {
+		fmt.Fprintf(&sb, "- %s (%d documents)", collection.Name, collection.Documents)
+		if collection.Description != "" {
+			fmt.Fprintf(&sb, ": %s", collection.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// allowedCollections returns the store collections, only the allowed ones if the list is not empty.
+// The agent memory collections are not listed.
+func allowedCollections(ctx context.Context, store VectorStore, allowed []string) ([]CollectionInfo, error) {
+	collections, err := store.Collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(collections, func(collection CollectionInfo) bool {
+		if strings.HasPrefix(collection.Name, MemoryCollectionPrefix) {
+			return true
+		}
+		return len(allowed) > 0 && !slices.Contains(allowed, collection.Name)
+	}), nil
+}
+
+// resolveCollection checks the collection argument against the allowed ones,
+// the only allowed collection is used if the argument is empty. The agent memory collections are rejected.
+func resolveCollection(collection string, allowed []string) (string, error) {
+	switch {
+	case collection == "" && len(allowed) == 1:
+		return allowed[0], nil
+	case collection == "":
+		return "", fmt.Errorf("empty collection, use listCollections to find the available ones")
+	case strings.HasPrefix(collection, MemoryCollectionPrefix):
+		return "", fmt.Errorf("collection %q is reserved for the agent memory", collection)
+	case len(allowed) > 0 && !slices.Contains(allowed, collection):
+		return "", fmt.Errorf("collection %q is not allowed, use one of: %s", collection, strings.Join(allowed, ", "))
+	}
+	return collection, nil
+}
+
+// checkCollection explains the empty search results caused by the wrong collection name.
+func checkCollection(ctx context.Context, store VectorStore, collection string, allowed []string) error {
+	collections, err := allowedCollections(ctx, store, allowed)
+	if err != nil {
+		// The results are just empty then
+		return nil
+	}
+	var names []string
+	for _, info := range collections {
+		if info.Name == collection && info.Documents > 0 {
+			return nil
+		}
+		if info.Documents > 0 {
+			names = append(names, info.Name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("collection %q does not exist or is empty, no collections are indexed", collection)
+	}
+	return fmt.Errorf("collection %q does not exist or is empty, available collections: %s", collection, strings.Join(names, ", "))
+}
+
+// pinCollections returns the definition with the collection argument limited to the allowed names.
+func pinCollections(definition llms.FunctionDefinition, allowed []string) llms.FunctionDefinition {
+	if len(allowed) == 0 {
+		return definition
+	}
+	parameters := maps.Clone(definition.Parameters.(map[string]any))
+	properties := maps.Clone(parameters["properties"].(map[string]any))
+
+	description := "name of the collection to search, one of: " + strings.Join(allowed, ", ")
+	if len(allowed) == 1 {
+		description = fmt.Sprintf("optional name of the collection to search, only %s is available", allowed[0])
+	}
+	properties["collection"] = map[string]any{
+		"type":        "string",
+		"enum":        allowed,
+		"description": description,
+	}
+	parameters["properties"] = properties
+	definition.Parameters = parameters
+	definition.Description += fmt.Sprintf("\nAvailable collections: %s.", strings.Join(allowed, ", "))
+	return definition
+}
+
+func init() {
+	globalToolsRegistry = append(globalToolsRegistry,
+		func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+			if cfg.SemanticSearchDisable {
+				return nil, nil
+			}
+			store, err := sharedVectorStore(ctx, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("list collections: %w", err)
+			}
+			listCollectionsTool := ListCollectionsTool{
+				Store:       store,
+				Collections: cfg.SemanticSearchCollections,
+			}
+
+			return &tools.ToolData{
+				Definition: ListCollectionsDefinition,
+				Call:       listCollectionsTool.Call,
+			}, nil
+		},
+	)
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"slices"
+)
+
+// Semantic search modes.
+const (
+	SemanticModeVector = "vector"
+	SemanticModeHybrid = "hybrid"
+)
+
+// rrfK is the reciprocal rank fusion constant, damping the weight of the top ranks.
+const rrfK = 60
+
+// SemanticFilter limits the searched chunks by their metadata.
+type SemanticFilter struct {
+	PathPrefix string
+	Language   string
+}
+
+// fuseResults merges the ranked lists with the reciprocal rank fusion, the chunk found by both searches
+// is ranked above the ones found by only one of them. The score of the fused results is the RRF score.
+func fuseResults(lists ...[]SemanticResult) []SemanticResult {
+	var fused []SemanticResult
+	positions := map[string]int{}
+	for _, list := range lists {
+		for rank, result := range list {
+			score := float32(1) / float32(rrfK+rank+1)
+			// The content starts with the chunk path and lines, so it identifies the chunk
+			if i, ok := positions[result.Content]; ok {
+				fused[i].Score += score
+				continue
+			}
+			positions[result.Content] = len(fused)
+			result.Score = score
+			fused = append(fused, result)
+		}
+	}
+	slices.SortStableFunc(fused, func(a, b SemanticResult) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return fused
+}
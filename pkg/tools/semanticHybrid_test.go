@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFuseResults(t *testing.T) {
+	result := func(content string) SemanticResult {
+		return SemanticResult{Content: content, Score: 0.5}
+	}
+	rank := func(ranks ...int) float32 {
+		score := float32(0)
+		for _, rank := range ranks {
+			score += float32(1) / float32(rrfK+rank)
+		}
+		return score
+	}
+
+	tests := []struct {
+		name       string
+		lists      [][]SemanticResult
+		want       []string
+		wantScores []float32
+	}{
+		{
+			name:       "rrf order",
+			lists:      [][]SemanticResult{{result("a"), result("b"), result("c")}, {result("c"), result("d")}},
+			want:       []string{"c", "a", "b", "d"},
+			wantScores: []float32{rank(3, 1), rank(1), rank(2), rank(2)},
+		},
+		{
+			name:       "ties keep the list order",
+			lists:      [][]SemanticResult{{result("a"), result("b")}, {result("c"), result("d")}},
+			want:       []string{"a", "c", "b", "d"},
+			wantScores: []float32{rank(1), rank(1), rank(2), rank(2)},
+		},
+		{
+			name:       "found by both ranks above the top of one",
+			lists:      [][]SemanticResult{{result("a"), result("b")}, {result("c"), result("b")}},
+			want:       []string{"b", "a", "c"},
+			wantScores: []float32{rank(2, 2), rank(1), rank(1)},
+		},
+		{
+			name:       "single list",
+			lists:      [][]SemanticResult{{result("a"), result("b")}, nil},
+			want:       []string{"a", "b"},
+			wantScores: []float32{rank(1), rank(2)},
+		},
+		{
+			name:  "empty",
+			lists: [][]SemanticResult{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fused := fuseResults(tt.lists...)
+			if len(fused) != len(tt.want) {
+				t.Fatalf("fused %+v, want %v", fused, tt.want)
+			}
+			for i, result := range fused {
+				if result.Content != tt.want[i] || math.Abs(float64(result.Score-tt.wantScores[i])) > 1e-6 {
+					t.Errorf("result %d %q score %f, want %q score %f", i, result.Content, result.Score, tt.want[i], tt.wantScores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFuseResultsDuplicateContent(t *testing.T) {
+	vector := []SemanticResult{{Path: "a.go", StartLine: 1, EndLine: 5, Content: "chunk", Metadata: map[string]any{"symbol": "A"}, Score: 0.9}}
+	lexical := []SemanticResult{{Content: "other", Score: 3}, {Path: "a.go", Content: "chunk", Score: 2}}
+
+	fused := fuseResults(vector, lexical)
+	if len(fused) != 2 {
+		t.Fatalf("fused %+v, want the duplicate chunk merged", fused)
+	}
+	// The first list fields are kept, the scores are summed
+	if got := fused[0]; got.Content != "chunk" || got.StartLine != 1 || got.Metadata["symbol"] != "A" {
+		t.Errorf("first result %+v, want the vector result fields", got)
+	}
+	if want := float32(1)/float32(rrfK+1) + float32(1)/float32(rrfK+2); math.Abs(float64(fused[0].Score-want)) > 1e-6 {
+		t.Errorf("score %f, want %f", fused[0].Score, want)
+	}
+	// The input lists keep their scores
+	if vector[0].Score != 0.9 || lexical[1].Score != 2 {
+		t.Errorf("input scores changed: %f, %f", vector[0].Score, lexical[1].Score)
+	}
+}
@@ -0,0 +1,455 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// DefaultIndexMaxFileSize is the size of the largest indexed file, the bigger ones are skipped.
+const DefaultIndexMaxFileSize = 1024 * 1024
+
+// DefaultIndexExclude are the base name patterns of the files and directories not indexed.
+var DefaultIndexExclude = []string{
+	".git", "node_modules", "vendor", ".venv", "venv", "__pycache__", ".idea", ".vscode",
+	"*.min.js", "*.min.css", "*.map", "*.lock", "go.sum", "package-lock.json", "yarn.lock",
+}
+
+// Metadata keys of the indexed chunks.
+const (
+	IndexMetadataPath      = "path"
+	IndexMetadataHash      = "hash"
+	IndexMetadataStartLine = "start_line"
+	IndexMetadataEndLine   = "end_line"
+	IndexMetadataLanguage  = "language"
+)
+
+// indexLanguages maps the file extensions to the language stored in the chunk metadata.
+var indexLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "javascript", ".ts": "typescript", ".tsx": "typescript",
+	".java": "java", ".kt": "kotlin", ".rs": "rust", ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".hpp": "cpp",
+	".cs": "csharp", ".rb": "ruby", ".php": "php", ".swift": "swift", ".sh": "shell", ".bash": "shell",
+	".sql": "sql", ".proto": "protobuf", ".html": "html", ".css": "css", ".md": "markdown",
+	".yaml": "yaml", ".yml": "yaml", ".json": "json", ".toml": "toml",
+}
+
+var IndexRepositoryDefinition = llms.FunctionDefinition{
+	Name: "indexRepository",
+	Description: `Indexes the files of a local directory or a git repository into a semantic search collection.
+Re-indexing is incremental: only the changed files are embedded again and the deleted files are removed from the collection.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"collection": map[string]any{
+				"type":        "string",
+				"description": "name of the collection to fill, created if missing",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "directory to index relative to the workspace root, used if repository is empty",
+			},
+			"repository": map[string]any{
+				"type":        "string",
+				"description": "optional remote git repository URL to clone and index",
+			},
+			"ref": map[string]any{
+				"type":        "string",
+				"description": "optional branch, tag or commit of the repository",
+			},
+			"description": map[string]any{
+				"type":        "string",
+				"description": "optional description of the collection content, shown by listCollections",
+			},
+		},
+	},
+}
+
+type IndexRepositoryArgs struct {
+	Collection  string `json:"collection"`
+	Path        string `json:"path,omitempty"`
+	Repository  string `json:"repository,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IndexStats describes the indexing run.
+type IndexStats struct {
+	Files     int `json:"files"`
+	Indexed   int `json:"indexed"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Skipped   int `json:"skipped"`
+	Chunks    int `json:"chunks"`
+}
+
+// Indexer fills the semantic search collections with the chunks of the files.
+// Every chunk keeps the file path and the content hash in the metadata, so the files are embedded
+// again only when their content changes.
+type Indexer struct {
+	Store VectorStore
+	// Chunker is GoChunker if nil
+	Chunker Chunker
+	// MaxFileSize is DefaultIndexMaxFileSize if zero
+	MaxFileSize int64
+	// Exclude are the base name patterns, DefaultIndexExclude if nil
+	Exclude []string
+}
+
+// IndexRepository clones the git repository at the ref into a temporary directory and indexes it.
+func (ix *Indexer) IndexRepository(ctx context.Context, repository, ref, collection string) (IndexStats, error) {
+	dir, err := os.MkdirTemp("", "libagent_index_")
+	if err != nil {
+		return IndexStats{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	workspace := CommandWorkspace{GitRepository: repository, GitRef: ref}
+	if err := workspace.prepare(ctx, dir); err != nil {
+		return IndexStats{}, err
+	}
+	return ix.IndexDirectory(ctx, dir, collection)
+}
+
+// IndexDirectory indexes the text files of the directory into the collection, with paths relative to it.
+// The files removed from the directory since the last run are removed from the collection.
+func (ix *Indexer) IndexDirectory(ctx context.Context, dir, collection string) (IndexStats, error) {
+	stats := IndexStats{}
+	if collection == "" {
+		return stats, fmt.Errorf("empty collection name")
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return stats, err
+	} else if !info.IsDir() {
+		return stats, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	indexed, err := ix.Store.IndexedFiles(ctx, collection)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := map[string]bool{}
+	err = filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if filePath != dir && ix.excluded(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		content, ok, err := ix.readFile(filePath, d)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			stats.Skipped++
+			return nil
+		}
+		stats.Files++
+		seen[rel] = true
+
+		hash := contentHash(content)
+		if indexed[rel] == hash {
+			stats.Unchanged++
+			return nil
+		}
+
+		chunks, err := ix.indexFile(ctx, collection, rel, hash, content, indexed[rel] != "")
+		if err != nil {
+			return fmt.Errorf("index %s: %w", rel, err)
+		}
+		stats.Indexed++
+		stats.Chunks += chunks
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	var removed []string
+	for rel := range indexed {
+		if !seen[rel] {
+			removed = append(removed, rel)
+		}
+	}
+	if err := ix.Store.RemoveFiles(ctx, collection, removed); err != nil {
+		return stats, err
+	}
+	stats.Removed = len(removed)
+	if err := ix.Store.Flush(ctx); err != nil {
+		return stats, err
+	}
+
+	log.Debug().Msgf("semantic index %s from %s: %+v", collection, dir, stats)
+	return stats, nil
+}
+
+func (ix *Indexer) excluded(name string) bool {
+	exclude := ix.Exclude
+	if exclude == nil {
+		exclude = DefaultIndexExclude
+	}
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile returns the file content, reporting false for the too big and binary files.
+func (ix *Indexer) readFile(filePath string, d fs.DirEntry) ([]byte, bool, error) {
+	info, err := d.Info()
+	if err != nil {
+		return nil, false, err
+	}
+	maxSize := ix.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = DefaultIndexMaxFileSize
+	}
+	if info.Size() > maxSize || info.Size() == 0 {
+		return nil, false, nil
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+	if isBinary(content) {
+		return nil, false, nil
+	}
+	return content, true, nil
+}
+
+// indexFile replaces the file chunks in the collection, returning the number of the added chunks.
+func (ix *Indexer) indexFile(ctx context.Context, collection, rel, hash string, content []byte, replace bool) (int, error) {
+	chunker := ix.Chunker
+	if chunker == nil {
+		chunker = GoChunker{}
+	}
+	chunks := chunker.Chunk(rel, content)
+
+	if replace {
+		if err := ix.Store.RemoveFiles(ctx, collection, []string{rel}); err != nil {
+			return 0, err
+		}
+	}
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	docs := make([]schema.Document, len(chunks))
+	for i, chunk := range chunks {
+		metadata := map[string]any{}
+		for key, value := range chunk.Metadata {
+			if value != "" {
+				metadata[key] = value
+			}
+		}
+		metadata[IndexMetadataPath] = rel
+		metadata[IndexMetadataHash] = hash
+		metadata[IndexMetadataStartLine] = chunk.StartLine
+		metadata[IndexMetadataEndLine] = chunk.EndLine
+		if language, ok := indexLanguages[strings.ToLower(path.Ext(rel))]; ok {
+			metadata[IndexMetadataLanguage] = language
+		}
+
+		docs[i] = schema.Document{
+			PageContent: chunkHeader(rel, chunk) + chunk.Content,
+			Metadata:    metadata,
+		}
+	}
+	if err := ix.Store.AddDocuments(ctx, collection, docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
+
+// chunkHeader describes the chunk location in the embedded text, so the queries can match the file and symbol names.
+func chunkHeader(rel string, chunk Chunk) string {
+	header := fmt.Sprintf("File: %s (lines %d-%d)\n", rel, chunk.StartLine, chunk.EndLine)
+	if symbol, _ := chunk.Metadata[IndexMetadataSymbol].(string); symbol != "" {
+		kind, _ := chunk.Metadata[IndexMetadataKind].(string)
+		pkg, _ := chunk.Metadata[IndexMetadataPackage].(string)
+		if kind == "package" {
+			return header + fmt.Sprintf("Package %s\n", pkg)
+		}
+		header += fmt.Sprintf("Package %s, %s %s\n", pkg, kind, symbol)
+	}
+	return header
+}
+
+// addIndexedFile records the hash of the indexed file chunk, the file is re-indexed if its chunks
+// have no hash or different ones.
+func addIndexedFile(indexed map[string]string, rel string, hash *string) {
+	if hash == nil {
+		indexed[rel] = "-"
+		return
+	}
+	if previous, ok := indexed[rel]; ok && previous != *hash {
+		// The file was partially re-indexed, the chunks will be replaced
+		indexed[rel] = "-"
+		return
+	}
+	indexed[rel] = *hash
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// IndexRepositoryTool is the indexRepository tool.
+// The local paths are confined to the Root directory and only the remote repositories are cloned,
+// so the model cannot index the host files outside of the workspace.
+type IndexRepositoryTool struct {
+	Indexer *Indexer
+	// Root is the directory of the indexed paths, the path indexing is disabled if empty
+	Root string
+	// Collections are the only collections allowed to fill if not empty, the single one is the default
+	Collections []string
+}
+
+func (t IndexRepositoryTool) Call(ctx context.Context, input string) (string, error) {
+	args := IndexRepositoryArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+
+	collection, err := resolveCollection(args.Collection, t.Collections)
+	if err != nil {
+		return "", err
+	}
+
+	var stats IndexStats
+	switch {
+	case args.Repository != "":
+		if !remoteRepository(args.Repository) {
+			return "", fmt.Errorf("repository %s is not a remote URL, index the workspace directories by path", args.Repository)
+		}
+		stats, err = t.Indexer.IndexRepository(ctx, args.Repository, args.Ref, collection)
+	case args.Path != "":
+		var dir string
+		dir, err = t.resolvePath(args.Path)
+		if err == nil {
+			stats, err = t.Indexer.IndexDirectory(ctx, dir, collection)
+		}
+	default:
+		err = errors.New("either path or repository is required")
+	}
+	if err != nil {
+		return "", err
+	}
+	if args.Description != "" {
+		if err := t.Indexer.Store.SetDescription(ctx, collection, args.Description); err != nil {
+			return "", err
+		}
+		if err := t.Indexer.Store.Flush(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf(
+		"Collection %s indexed: %d files (%d indexed, %d unchanged, %d skipped), %d chunks added, %d files removed",
+		collection, stats.Files, stats.Indexed, stats.Unchanged, stats.Skipped, stats.Chunks, stats.Removed,
+	), nil
+}
+
+// resolvePath returns the absolute directory of the path inside the root.
+func (t IndexRepositoryTool) resolvePath(path string) (string, error) {
+	if t.Root == "" {
+		return "", errors.New("path indexing is disabled, no workspace root is configured")
+	}
+	root, err := filepath.Abs(t.Root)
+	if err != nil {
+		return "", err
+	}
+	return resolveInRoot(root, path)
+}
+
+// remoteRepository reports whether git clones the repository from the network, not from a local path.
+func remoteRepository(repository string) bool {
+	if strings.HasPrefix(repository, "-") || strings.Contains(repository, "::") {
+		return false
+	}
+	if scheme, _, ok := strings.Cut(repository, "://"); ok {
+		return slices.Contains([]string{"http", "https", "ssh", "git", "git+ssh", "ssh+git"}, strings.ToLower(scheme))
+	}
+	// The scp-like syntax, like git@github.com:owner/repo.git
+	colon := strings.Index(repository, ":")
+	slash := strings.Index(repository, "/")
+	return colon > 0 && (slash == -1 || colon < slash)
+}
+
+func init() {
+	globalToolsRegistry = append(globalToolsRegistry,
+		func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+			if cfg.SemanticSearchDisable || cfg.SemanticIndexDisable {
+				return nil, nil
+			}
+			if cfg.SemanticSearchEmbeddingModel == "" {
+				return nil, fmt.Errorf("semantic index empty embedding model")
+			}
+
+			store, err := sharedVectorStore(ctx, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("semantic index: %w", err)
+			}
+			root := cfg.SemanticIndexRoot
+			if root == "" && !cfg.FileToolsDisable {
+				root = cfg.FileToolsRoot
+			}
+			indexRepositoryTool := IndexRepositoryTool{
+				Indexer: &Indexer{
+					Store: store,
+					Chunker: GoChunker{
+						Fallback: LineChunker{
+							MaxChars:     cfg.SemanticIndexChunkSize,
+							OverlapLines: DefaultChunkOverlapLines,
+						},
+						MaxChars: cfg.SemanticIndexChunkSize,
+					},
+					MaxFileSize: int64(cfg.SemanticIndexMaxFileSize),
+				},
+				Root:        root,
+				Collections: cfg.SemanticSearchCollections,
+			}
+
+			return &tools.ToolData{
+				Definition: IndexRepositoryDefinition,
+				Call:       indexRepositoryTool.Call,
+			}, nil
+		},
+	)
+}
@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingEmbedder counts the embedded documents of fakeEmbedder.
+type countingEmbedder struct {
+	fakeEmbedder
+	documents int
+}
+
+func (e *countingEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	e.documents += len(texts)
+	return e.fakeEmbedder.EmbedDocuments(ctx, texts)
+}
+
+// writeFiles writes the files of the test tree into the directory, the empty content removes the file.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIndexDirectoryIncremental(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	embedder := &countingEmbedder{}
+	store := &MemoryVectorStore{Embedder: embedder}
+	indexer := &Indexer{Store: store, Chunker: LineChunker{}}
+
+	writeFiles(t, dir, map[string]string{
+		"main.go":             "package main\n\nfunc main() {}\n",
+		"docs/readme.md":      "first version\n",
+		"docs/removed.md":     "removed later\n",
+		"node_modules/lib.js": "excluded\n",
+		"image.png":           "binary\x00content",
+	})
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		want      IndexStats
+		embedded  int
+		wantFiles []string
+	}{
+		{
+			name:      "first run",
+			want:      IndexStats{Files: 3, Indexed: 3, Skipped: 1, Chunks: 3},
+			embedded:  3,
+			wantFiles: []string{"docs/readme.md", "docs/removed.md", "main.go"},
+		},
+		{
+			name:      "unchanged",
+			want:      IndexStats{Files: 3, Unchanged: 3, Skipped: 1},
+			wantFiles: []string{"docs/readme.md", "docs/removed.md", "main.go"},
+		},
+		{
+			name:      "changed and deleted",
+			files:     map[string]string{"docs/readme.md": "second version\n", "docs/removed.md": ""},
+			want:      IndexStats{Files: 2, Indexed: 1, Unchanged: 1, Removed: 1, Skipped: 1, Chunks: 1},
+			embedded:  1,
+			wantFiles: []string{"docs/readme.md", "main.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, dir, tt.files)
+			embedder.documents = 0
+
+			stats, err := indexer.IndexDirectory(ctx, dir, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != tt.want {
+				t.Errorf("stats %+v, want %+v", stats, tt.want)
+			}
+			if embedder.documents != tt.embedded {
+				t.Errorf("%d documents embedded, want %d", embedder.documents, tt.embedded)
+			}
+
+			indexed, err := store.IndexedFiles(ctx, "repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(indexed) != len(tt.wantFiles) {
+				t.Errorf("indexed files %v, want %v", indexed, tt.wantFiles)
+			}
+			for _, rel := range tt.wantFiles {
+				content, err := os.ReadFile(filepath.Join(dir, rel))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if indexed[rel] != contentHash(content) {
+					t.Errorf("file %s hash %q, want the current content hash", rel, indexed[rel])
+				}
+			}
+		})
+	}
+
+	// The chunks of the changed file are replaced, not added
+	results, err := store.LexicalSearch(ctx, "repo", "version", SemanticFilter{PathPrefix: "docs/"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Content, "second version") || results[0].StartLine != 1 {
+		t.Errorf("results %+v, want only the second version chunk", results)
+	}
+}
+
+func TestIndexRepositoryTool(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, root, map[string]string{"project/main.go": "package main\n"})
+	writeFiles(t, outside, map[string]string{"secret.txt": "secret\n"})
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		root        string
+		collections []string
+		input       string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:  "relative path",
+			root:  root,
+			input: `{"collection": "repo", "path": "project"}`,
+			want:  "Collection repo indexed: 1 files",
+		},
+		{
+			name:  "absolute path inside the root",
+			root:  root,
+			input: `{"collection": "repo", "path": "` + filepath.Join(root, "project") + `"}`,
+			want:  "Collection repo indexed: 1 files",
+		},
+		{
+			name:        "single allowed collection",
+			root:        root,
+			collections: []string{"repo"},
+			input:       `{"path": "project"}`,
+			want:        "Collection repo indexed: 1 files",
+		},
+		{
+			name:    "path outside the root",
+			root:    root,
+			input:   `{"collection": "repo", "path": "` + outside + `"}`,
+			wantErr: "outside of the workspace",
+		},
+		{
+			name:    "parent path",
+			root:    root,
+			input:   `{"collection": "repo", "path": "../"}`,
+			wantErr: "outside of the workspace",
+		},
+		{
+			name:    "symlink outside the root",
+			root:    root,
+			input:   `{"collection": "repo", "path": "link"}`,
+			wantErr: "outside of the workspace",
+		},
+		{
+			name:    "no root",
+			input:   `{"collection": "repo", "path": "` + root + `"}`,
+			wantErr: "path indexing is disabled",
+		},
+		{
+			name:    "local repository",
+			root:    root,
+			input:   `{"collection": "repo", "repository": "` + outside + `"}`,
+			wantErr: "not a remote URL",
+		},
+		{
+			name:    "file repository",
+			root:    root,
+			input:   `{"collection": "repo", "repository": "file://` + outside + `"}`,
+			wantErr: "not a remote URL",
+		},
+		{
+			name:    "memory collection",
+			root:    root,
+			input:   `{"collection": "` + MemoryCollectionPrefix + `default", "path": "project"}`,
+			wantErr: "reserved for the agent memory",
+		},
+		{
+			name:        "collection not allowed",
+			root:        root,
+			collections: []string{"repo", "docs"},
+			input:       `{"collection": "other", "path": "project"}`,
+			wantErr:     "is not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MemoryVectorStore{Embedder: fakeEmbedder{}}
+			tool := IndexRepositoryTool{
+				Indexer:     &Indexer{Store: store},
+				Root:        tt.root,
+				Collections: tt.collections,
+			}
+			output, err := tool.Call(ctx, tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want %q", err, tt.wantErr)
+				}
+				if collections, _ := store.Collections(ctx); len(collections) != 0 {
+					t.Errorf("collections %+v, want nothing indexed", collections)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(output, tt.want) {
+				t.Errorf("output %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteRepository(t *testing.T) {
+	tests := []struct {
+		repository string
+		want       bool
+	}{
+		{"https://github.com/Swarmind/libagent.git", true},
+		{"ssh://git@github.com/Swarmind/libagent.git", true},
+		{"git@github.com:Swarmind/libagent.git", true},
+		{"/home/user/project", false},
+		{"./project", false},
+		{"project", false},
+		{"dir/name:with-colon", false},
+		{"file:///home/user/project", false},
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"--upload-pack=touch /tmp/pwned", false},
+	}
+	for _, tt := range tests {
+		if got := remoteRepository(tt.repository); got != tt.want {
+			t.Errorf("remoteRepository(%q) = %t, want %t", tt.repository, got, tt.want)
+		}
+	}
+}
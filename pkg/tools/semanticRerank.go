@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// DefaultRerankTopK is the number of the search candidates passed to the reranker.
+const DefaultRerankTopK = 20
+
+// Semantic search rerankers.
+const (
+	SemanticRerankerLLM = "llm"
+	SemanticRerankerAPI = "api"
+)
+
+// Passages are cut to this size in the LLM rerank prompt
+const maxRerankPassageChars = 1000
+
+// Reranker reorders the search candidates by their relevance to the query.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results []SemanticResult) ([]SemanticResult, error)
+}
+
+// APIReranker scores the candidates with the cross-encoder behind the /rerank API,
+// as served by Cohere, Jina, text-embeddings-inference or llama.cpp.
+type APIReranker struct {
+	URL    string
+	APIKey string
+	Model  string
+	Client *http.Client
+}
+
+func (r APIReranker) Rerank(ctx context.Context, query string, results []SemanticResult) ([]SemanticResult, error) {
+	documents := make([]string, len(results))
+	for i, result := range results {
+		documents[i] = result.Content
+	}
+	body, err := json.Marshal(map[string]any{
+		"model":     r.Model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("rerank status code error: %d %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	response := struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float32 `json:"relevance_score"`
+		} `json:"results"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode rerank response: %w", err)
+	}
+
+	scored := make([]SemanticResult, len(results))
+	copy(scored, results)
+	for i := range scored {
+		scored[i].Score = 0
+	}
+	order := make([]int, 0, len(response.Results))
+	for _, result := range response.Results {
+		if result.Index >= 0 && result.Index < len(scored) {
+			scored[result.Index].Score = result.RelevanceScore
+			order = append(order, result.Index)
+		}
+	}
+	return reorderResults(scored, order), nil
+}
+
+// LLMReranker asks the model to order the candidates by relevance, the score of the reranked results
+// decreases with their position from 1 for the first one.
+type LLMReranker struct {
+	LLM         llms.Model
+	CallOptions []llms.CallOption
+}
+
+var rerankIndexesRe = regexp.MustCompile(`\[[\d\s,]*\]`)
+
+func (r LLMReranker) Rerank(ctx context.Context, query string, results []SemanticResult) ([]SemanticResult, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Order the passages below by their relevance to the query %q.\n", query)
+	sb.WriteString("Answer only with the JSON array of the passage numbers, the most relevant first, for example [3, 1, 2].\n\n")
+	for i, result := range results {
+		fmt.Fprintf(&sb, "[%d]\n%s\n\n", i+1, truncateRunes(result.Content, maxRerankPassageChars))
+	}
+
+	answer, err := llms.GenerateFromSinglePrompt(ctx, r.LLM, sb.String(), r.CallOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	var numbers []int
+	if err := json.Unmarshal([]byte(rerankIndexesRe.FindString(answer)), &numbers); err != nil {
+		return nil, fmt.Errorf("rerank: unexpected answer %q", truncateRunes(answer, 200))
+	}
+
+	order := make([]int, len(numbers))
+	for i, number := range numbers {
+		order[i] = number - 1
+	}
+	reranked := reorderResults(results, order)
+	for i := range reranked {
+		reranked[i].Score = float32(len(reranked)-i) / float32(len(reranked))
+	}
+	return reranked, nil
+}
+
+// NewReranker returns the reranker configured by SemanticSearchReranker, nil if the reranking is disabled.
+// The LLM reranker uses SemanticSearchRerankModel or the main model.
+func NewReranker(cfg config.Config) (Reranker, error) {
+	switch cfg.SemanticSearchReranker {
+	case "":
+		return nil, nil
+	case SemanticRerankerAPI:
+		if cfg.SemanticSearchRerankURL == "" {
+			return nil, fmt.Errorf("empty rerank URL")
+		}
+		return APIReranker{
+			URL:    cfg.SemanticSearchRerankURL,
+			APIKey: cfg.SemanticSearchRerankToken,
+			Model:  cfg.SemanticSearchRerankModel,
+		}, nil
+	case SemanticRerankerLLM:
+		model := cfg.SemanticSearchRerankModel
+		if model == "" {
+			model = cfg.Model
+		}
+		llm, err := openai.New(
+			openai.WithBaseURL(cfg.AIURL),
+			openai.WithToken(cfg.AIToken),
+			openai.WithModel(model),
+			openai.WithAPIVersion("v1"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return LLMReranker{LLM: llm, CallOptions: []llms.CallOption{llms.WithTemperature(0)}}, nil
+	}
+	return nil, fmt.Errorf("unknown reranker %q", cfg.SemanticSearchReranker)
+}
+
+// reorderResults puts the results in the order of the indexes, the results not listed follow in their order.
+func reorderResults(results []SemanticResult, order []int) []SemanticResult {
+	reordered := make([]SemanticResult, 0, len(results))
+	used := make([]bool, len(results))
+	for _, index := range order {
+		if index < 0 || index >= len(results) || used[index] {
+			continue
+		}
+		used[index] = true
+		reordered = append(reordered, results[index])
+	}
+	for i, result := range results {
+		if !used[i] {
+			reordered = append(reordered, result)
+		}
+	}
+	return reordered
+}
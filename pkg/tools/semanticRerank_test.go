@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Swarmind/libagent/pkg/config"
+)
+
+// rerankCandidates returns the results with the given contents and the fused scores.
+func rerankCandidates(contents ...string) []SemanticResult {
+	results := make([]SemanticResult, len(contents))
+	for i, content := range contents {
+		results[i] = SemanticResult{Content: content, Score: 0.01}
+	}
+	return results
+}
+
+// checkReranked compares the order and the scores of the reranked results.
+func checkReranked(t *testing.T, reranked []SemanticResult, want []string, wantScores []float32) {
+	t.Helper()
+	if len(reranked) != len(want) {
+		t.Fatalf("reranked %+v, want %v", reranked, want)
+	}
+	for i, result := range reranked {
+		if result.Content != want[i] || fmt.Sprintf("%.3f", result.Score) != fmt.Sprintf("%.3f", wantScores[i]) {
+			t.Errorf("result %d %q score %f, want %q score %f", i, result.Content, result.Score, want[i], wantScores[i])
+		}
+	}
+}
+
+func TestAPIReranker(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		want       []string
+		wantScores []float32
+		wantErr    string
+	}{
+		{
+			name:       "ordered by relevance",
+			body:       `{"results": [{"index": 2, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.5}, {"index": 1, "relevance_score": 0.1}]}`,
+			want:       []string{"c", "a", "b"},
+			wantScores: []float32{0.9, 0.5, 0.1},
+		},
+		{
+			name:       "missing and invalid indexes",
+			body:       `{"results": [{"index": 5, "relevance_score": 0.9}, {"index": -1, "relevance_score": 0.8}, {"index": 1, "relevance_score": 0.7}]}`,
+			want:       []string{"b", "a", "c"},
+			wantScores: []float32{0.7, 0, 0},
+		},
+		{
+			name:    "status error",
+			status:  http.StatusTooManyRequests,
+			body:    "rate limited\n",
+			wantErr: "rerank status code error: 429 rate limited",
+		},
+		{
+			name:    "invalid response",
+			body:    `{"results": [`,
+			wantErr: "decode rerank response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+					t.Errorf("authorization %q", auth)
+				}
+				request := struct {
+					Model     string   `json:"model"`
+					Query     string   `json:"query"`
+					Documents []string `json:"documents"`
+					TopN      int      `json:"top_n"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Error(err)
+				}
+				if request.Model != "reranker" || request.Query != "query" || strings.Join(request.Documents, ",") != "a,b,c" || request.TopN != 3 {
+					t.Errorf("request %+v", request)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			reranker := APIReranker{URL: server.URL, APIKey: "token", Model: "reranker"}
+			reranked, err := reranker.Rerank(context.Background(), "query", rerankCandidates("a", "b", "c"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkReranked(t, reranked, tt.want, tt.wantScores)
+		})
+	}
+}
+
+func TestLLMReranker(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		answer     string
+		want       []string
+		wantScores []float32
+		wantErr    string
+	}{
+		{
+			name:       "ordered by relevance",
+			answer:     "[3, 1, 2]",
+			want:       []string{"c", "a", "b"},
+			wantScores: []float32{1, 2.0 / 3, 1.0 / 3},
+		},
+		{
+			name:       "array in the text",
+			answer:     "The most relevant passages are [2,3] since they mention the query.",
+			want:       []string{"b", "c", "a"},
+			wantScores: []float32{1, 2.0 / 3, 1.0 / 3},
+		},
+		{
+			name:       "duplicate and invalid numbers",
+			answer:     "[2, 2, 0, 7]",
+			want:       []string{"b", "a", "c"},
+			wantScores: []float32{1, 2.0 / 3, 1.0 / 3},
+		},
+		{
+			name:    "no array",
+			answer:  "All of them are relevant.",
+			wantErr: "rerank: unexpected answer",
+		},
+		{
+			name:    "status error",
+			status:  http.StatusBadRequest,
+			wantErr: "rerank:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request := struct {
+					Model    string `json:"model"`
+					Messages []struct {
+						Content string `json:"content"`
+					} `json:"messages"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Error(err)
+				}
+				if request.Model != "reranker" || len(request.Messages) != 1 {
+					t.Errorf("request %+v", request)
+				} else if prompt := request.Messages[0].Content; !strings.Contains(prompt, `query "query"`) || !strings.Contains(prompt, "[3]\nc\n") {
+					t.Errorf("prompt %q, want the query and the numbered passages", prompt)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, `{"error": {"message": "bad request"}}`)
+					return
+				}
+				answer, _ := json.Marshal(tt.answer)
+				fmt.Fprintf(w, `{"id": "1", "object": "chat.completion", "model": "reranker",
+"choices": [{"index": 0, "message": {"role": "assistant", "content": %s}, "finish_reason": "stop"}]}`, answer)
+			}))
+			defer server.Close()
+
+			reranker, err := NewReranker(config.Config{
+				SemanticSearchReranker:    SemanticRerankerLLM,
+				SemanticSearchRerankModel: "reranker",
+				AIURL:                     server.URL,
+				AIToken:                   "token",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reranked, err := reranker.Rerank(context.Background(), "query", rerankCandidates("a", "b", "c"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkReranked(t, reranked, tt.want, tt.wantScores)
+		})
+	}
+}
+
+func TestNewReranker(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    string
+		wantErr bool
+	}{
+		{"disabled", config.Config{}, "<nil>", false},
+		{"api", config.Config{SemanticSearchReranker: SemanticRerankerAPI, SemanticSearchRerankURL: "http://localhost/rerank"}, "tools.APIReranker", false},
+		{"api without url", config.Config{SemanticSearchReranker: SemanticRerankerAPI}, "", true},
+		{"llm", config.Config{SemanticSearchReranker: SemanticRerankerLLM, AIToken: "token"}, "tools.LLMReranker", false},
+		{"unknown", config.Config{SemanticSearchReranker: "cohere"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reranker, err := NewReranker(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+			if got := fmt.Sprintf("%T", reranker); !tt.wantErr && got != tt.want {
+				t.Errorf("reranker %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
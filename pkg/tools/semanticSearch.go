@@ -4,20 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	"github.com/Swarmind/libagent/pkg/config"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/tmc/langchaingo/embeddings"
+	"github.com/rs/zerolog/log"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
-	"github.com/tmc/langchaingo/vectorstores/pgvector"
 )
 
+// maxSemanticResults bounds the per-call max_results argument.
+const maxSemanticResults = 50
+
 var SemanticSearchDefinition = llms.FunctionDefinition{
-	Name:        "semanticSearch",
-	Description: "Performs semantic search in the vector store of the saved code blobs. Returns matching file contents",
+	Name: "semanticSearch",
+	Description: `Performs semantic search in the vector store of the saved code blobs.
+Returns the matching chunks with their file path, line range, relevance score and metadata.`,
 	Parameters: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
@@ -29,77 +33,237 @@ var SemanticSearchDefinition = llms.FunctionDefinition{
 				"type":        "string",
 				"description": "name of collection store in which we perform the search",
 			},
+			"path_prefix": map[string]any{
+				"type":        "string",
+				"description": "optional path prefix of the files to search in, like pkg/tools/",
+			},
+			"language": map[string]any{
+				"type":        "string",
+				"description": "optional language of the files to search in, like go or python",
+			},
+			"min_score": map[string]any{
+				"type":        "number",
+				"description": "optional minimal vector similarity score from 0 to 1",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": "optional number of the results",
+			},
+			"mode": map[string]any{
+				"type":        "string",
+				"description": "optional search mode: vector, or hybrid to also match the exact identifiers and error strings",
+			},
 		},
 	},
 }
 
 type SemanticSearchArgs struct {
-	Query      string `json:"query"`
-	Collection string `json:"collection"`
+	Query      string  `json:"query"`
+	Collection string  `json:"collection"`
+	PathPrefix string  `json:"path_prefix,omitempty"`
+	Language   string  `json:"language,omitempty"`
+	MinScore   float32 `json:"min_score,omitempty"`
+	MaxResults int     `json:"max_results,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
+}
+
+// SemanticResult is the matched chunk, the path and the lines are empty for the documents stored without them.
+type SemanticResult struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Score     float32
+	Content   string
+	// Metadata are the other stored metadata
+	Metadata map[string]any
 }
 
+// SemanticSearchTool queries the collections of the vector store shared by the concurrent calls.
+// The Postgres store is created from the connection fields if Store is nil.
 type SemanticSearchTool struct {
+	Store VectorStore
+
 	OpenAIURL      string
 	OpenAIToken    string
 	DBConnection   string
 	EmbeddingModel string
 	MaxResults     int
+	// Mode is the default search mode, SemanticModeVector if empty
+	Mode string
+	// Reranker reorders the RerankTopK best candidates if set
+	Reranker   Reranker
+	RerankTopK int
+	// Collections are the only collections allowed to search if not empty, the single one is the default
+	Collections []string
+
+	storeOnce sync.Once
 }
 
-func (s SemanticSearchTool) Call(ctx context.Context, input string) (string, error) {
+func (s *SemanticSearchTool) Call(ctx context.Context, input string) (string, error) {
 	semanticSearchArgs := SemanticSearchArgs{}
-	response := ""
-
 	if err := json.Unmarshal([]byte(input), &semanticSearchArgs); err != nil {
-		return response, err
+		return "", err
 	}
 
-	config, err := pgxpool.ParseConfig(s.DBConnection)
+	results, err := s.Search(ctx, semanticSearchArgs)
 	if err != nil {
-		return response, err
+		return "", err
 	}
+	return RenderSemanticResults(results), nil
+}
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
-	if err != nil {
-		return response, err
+// Search returns the collection chunks matching the query and the filters of the arguments.
+// The hybrid mode fuses the vector and the lexical search results, the reranker if set reorders
+// the top candidates.
+func (s *SemanticSearchTool) Search(ctx context.Context, args SemanticSearchArgs) ([]SemanticResult, error) {
+	if args.MinScore < 0 || args.MinScore > 1 {
+		return nil, fmt.Errorf("min_score must be between 0 and 1")
+	}
+	mode := args.Mode
+	if mode == "" {
+		mode = s.Mode
+	}
+	if mode == "" {
+		mode = SemanticModeVector
+	}
+	if mode != SemanticModeVector && mode != SemanticModeHybrid {
+		return nil, fmt.Errorf("unknown search mode %q", mode)
 	}
+	maxResults := s.MaxResults
+	if args.MaxResults > 0 {
+		maxResults = min(args.MaxResults, maxSemanticResults)
+	}
+	filter := SemanticFilter{PathPrefix: args.PathPrefix, Language: strings.ToLower(args.Language)}
 
-	llm, err := openai.New(
-		openai.WithBaseURL(s.OpenAIURL),
-		openai.WithToken(s.OpenAIToken),
-		openai.WithEmbeddingModel(s.EmbeddingModel),
-		openai.WithAPIVersion("v1"),
-	)
-	if err != nil {
-		return response, err
+	candidates := maxResults
+	if mode == SemanticModeHybrid {
+		candidates *= 2
+	}
+	topK := s.RerankTopK
+	if topK <= 0 {
+		topK = DefaultRerankTopK
+	}
+	if s.Reranker != nil {
+		candidates = max(candidates, topK)
 	}
 
-	e, err := embeddings.NewEmbedder(llm)
+	collection, err := resolveCollection(args.Collection, s.Collections)
 	if err != nil {
-		return response, err
+		return nil, err
 	}
-
-	store, err := pgvector.New(
-		context.Background(),
-		pgvector.WithCollectionName(semanticSearchArgs.Collection),
-		pgvector.WithConn(pool),
-		pgvector.WithEmbedder(e),
-	)
-	defer store.Close()
+	store := s.semanticStore()
+	results, err := store.SimilaritySearch(ctx, collection, args.Query, filter, args.MinScore, candidates)
 	if err != nil {
-		return response, err
+		return nil, err
+	}
+	if mode == SemanticModeHybrid {
+		lexical, err := store.LexicalSearch(ctx, collection, args.Query, filter, candidates)
+		if err != nil {
+			return nil, err
+		}
+		results = fuseResults(results, lexical)
+	}
+	if len(results) == 0 {
+		if err := checkCollection(ctx, store, collection, s.Collections); err != nil {
+			return nil, err
+		}
 	}
 
-	searchResults, err := store.SimilaritySearch(ctx, semanticSearchArgs.Query, s.MaxResults)
-	if err != nil {
-		return response, err
+	if s.Reranker != nil && len(results) > 1 {
+		reranked, err := s.Reranker.Rerank(ctx, args.Query, results[:min(len(results), topK)])
+		if err != nil {
+			// The fused order is still usable
+			log.Warn().Err(err).Msg("semantic search rerank")
+		} else {
+			results = reranked
+		}
+	}
+	return results[:min(len(results), maxResults)], nil
+}
+
+// semanticResultFromMetadata splits the stored metadata into the result fields.
+func semanticResultFromMetadata(content string, metadata map[string]any) SemanticResult {
+	result := SemanticResult{
+		Content:  content,
+		Metadata: map[string]any{},
+	}
+	for key, value := range metadata {
+		switch key {
+		case IndexMetadataPath:
+			result.Path, _ = value.(string)
+		case IndexMetadataStartLine:
+			result.StartLine = metadataInt(value)
+		case IndexMetadataEndLine:
+			result.EndLine = metadataInt(value)
+		// The hash is internal and the doc comment is a part of the content
+		case IndexMetadataHash, IndexMetadataDoc:
+		default:
+			result.Metadata[key] = value
+		}
+	}
+	return result
+}
+
+// metadataInt converts the number decoded from the JSON metadata.
+func metadataInt(value any) int {
+	switch value := value.(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	}
+	return 0
+}
+
+// RenderSemanticResults formats the results for the model, best first.
+func RenderSemanticResults(results []SemanticResult) string {
+	if len(results) == 0 {
+		return "No results found."
 	}
 
-	for _, result := range searchResults {
-		response += fmt.Sprintf("%s\n", result.PageContent)
+	var sb strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&sb, "%d. ", i+1)
+		if result.Path != "" {
+			sb.WriteString(result.Path)
+			if result.StartLine > 0 {
+				fmt.Fprintf(&sb, ":%d-%d", result.StartLine, result.EndLine)
+			}
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "(score %.3f)\n", result.Score)
+
+		keys := make([]string, 0, len(result.Metadata))
+		for key := range result.Metadata {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "%s: %v\n", key, result.Metadata[key])
+		}
+		sb.WriteString(strings.TrimRight(result.Content, "\n"))
+		sb.WriteString("\n\n")
 	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (s *SemanticSearchTool) semanticStore() VectorStore {
+	s.storeOnce.Do(func() {
+		if s.Store == nil {
+			s.Store = &PGVectorStore{
+				OpenAIURL:      s.OpenAIURL,
+				OpenAIToken:    s.OpenAIToken,
+				DBConnection:   s.DBConnection,
+				EmbeddingModel: s.EmbeddingModel,
+			}
+		}
+	})
+	return s.Store
+}
 
-	return response, nil
+// Cleanup closes the store connections.
+func (s *SemanticSearchTool) Cleanup() error {
+	return s.semanticStore().Close()
 }
 
 func init() {
@@ -114,26 +278,39 @@ func init() {
 			if cfg.SemanticSearchAIToken == "" {
 				return nil, fmt.Errorf("semantic search empty OpenAI Token")
 			}
-			if cfg.SemanticSearchDBConnection == "" {
-				return nil, fmt.Errorf("semantic search empty DB connection string")
-			}
 			if cfg.SemanticSearchEmbeddingModel == "" {
 				return nil, fmt.Errorf("semantic search empty embedding model")
 			}
 			if cfg.SemanticSearchMaxResults == 0 {
 				cfg.SemanticSearchMaxResults = 2
 			}
+			if cfg.SemanticSearchMode != "" && cfg.SemanticSearchMode != SemanticModeVector && cfg.SemanticSearchMode != SemanticModeHybrid {
+				return nil, fmt.Errorf("semantic search unknown mode %q", cfg.SemanticSearchMode)
+			}
+			reranker, err := NewReranker(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("semantic search: %w", err)
+			}
+			store, err := sharedVectorStore(ctx, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("semantic search: %w", err)
+			}
 
 			semanticSearchTool := &SemanticSearchTool{
+				Store:          store,
 				OpenAIURL:      cfg.SemanticSearchAIURL,
 				OpenAIToken:    cfg.SemanticSearchAIToken,
 				DBConnection:   cfg.SemanticSearchDBConnection,
 				EmbeddingModel: cfg.SemanticSearchEmbeddingModel,
 				MaxResults:     cfg.SemanticSearchMaxResults,
+				Mode:           cfg.SemanticSearchMode,
+				Reranker:       reranker,
+				RerankTopK:     cfg.SemanticSearchRerankTopK,
+				Collections:    cfg.SemanticSearchCollections,
 			}
 
 			return &tools.ToolData{
-				Definition: SemanticSearchDefinition,
+				Definition: pinCollections(SemanticSearchDefinition, cfg.SemanticSearchCollections),
 				Call:       semanticSearchTool.Call,
 			}, nil
 		},
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Semantic search store backends.
+const (
+	SemanticBackendPostgres = "postgres"
+	SemanticBackendMemory   = "memory"
+)
+
+// VectorStore keeps the embedded documents of the semantic search in the named collections,
+// the collections are created by AddDocuments, the searches of the missing ones return no results.
+type VectorStore interface {
+	AddDocuments(ctx context.Context, collection string, docs []schema.Document) error
+	// SimilaritySearch returns at most limit documents closest to the query with the score of at least minScore
+	SimilaritySearch(ctx context.Context, collection, query string, filter SemanticFilter, minScore float32, limit int) ([]SemanticResult, error)
+	// LexicalSearch returns at most limit documents containing the query terms
+	LexicalSearch(ctx context.Context, collection, query string, filter SemanticFilter, limit int) ([]SemanticResult, error)
+	// IndexedFiles returns the content hashes of the indexed files by path
+	IndexedFiles(ctx context.Context, collection string) (map[string]string, error)
+	// RemoveFiles deletes the documents of the indexed files
+	RemoveFiles(ctx context.Context, collection string, paths []string) error
+	// Collections returns the collections sorted by name
+	Collections(ctx context.Context) ([]CollectionInfo, error)
+	// SetDescription describes the collection listed by Collections
+	SetDescription(ctx context.Context, collection, description string) error
+	// Flush persists the pending changes
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// CollectionInfo describes the stored collection.
+type CollectionInfo struct {
+	Name        string
+	Documents   int
+	Description string
+}
+
+// NewVectorStore returns the new store of the configured backend, postgres by default.
+func NewVectorStore(cfg config.Config) (VectorStore, error) {
+	switch cfg.SemanticSearchBackend {
+	case "", SemanticBackendPostgres:
+		if cfg.SemanticSearchDBConnection == "" {
+			return nil, fmt.Errorf("empty DB connection string")
+		}
+		return &PGVectorStore{
+			OpenAIURL:      cfg.SemanticSearchAIURL,
+			OpenAIToken:    cfg.SemanticSearchAIToken,
+			DBConnection:   cfg.SemanticSearchDBConnection,
+			EmbeddingModel: cfg.SemanticSearchEmbeddingModel,
+		}, nil
+	case SemanticBackendMemory:
+		embedder, err := newEmbedder(cfg.SemanticSearchAIURL, cfg.SemanticSearchAIToken, cfg.SemanticSearchEmbeddingModel)
+		if err != nil {
+			return nil, err
+		}
+		return &MemoryVectorStore{
+			Embedder: embedder,
+			Path:     cfg.SemanticSearchStorePath,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown store backend %q", cfg.SemanticSearchBackend)
+}
+
+// sharedVectorStore returns the store shared by the semantic search, indexing, collections and memory tools
+// of the executor, so they see the same documents and the memory store file has the single writer.
+func sharedVectorStore(ctx context.Context, cfg config.Config) (VectorStore, error) {
+	return sharedResource(ctx, "vectorStore", func() (VectorStore, func() error, error) {
+		store, err := NewVectorStore(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store.Close, nil
+	})
+}
+
+func newEmbedder(openAIURL, openAIToken, model string) (embeddings.Embedder, error) {
+	llm, err := openai.New(
+		openai.WithBaseURL(openAIURL),
+		openai.WithToken(openAIToken),
+		openai.WithEmbeddingModel(model),
+		openai.WithAPIVersion("v1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings.NewEmbedder(llm)
+}
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Swarmind/libagent/pkg/config"
+)
+
+func TestSharedVectorStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{
+		SemanticSearchBackend:        SemanticBackendMemory,
+		SemanticSearchAIURL:          "http://localhost/v1",
+		SemanticSearchAIToken:        "test",
+		SemanticSearchEmbeddingModel: "test",
+		SemanticIndexRoot:            t.TempDir(),
+		ReWOODisable:                 true,
+	}
+	newExecutor := func() func(name, input string) string {
+		t.Helper()
+		toolsExecutor, err := NewToolsExecutor(ctx, cfg,
+			WithToolsWhitelist(IndexRepositoryDefinition.Name, ListCollectionsDefinition.Name, SemanticSearchDefinition.Name),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := toolsExecutor.Cleanup(); err != nil {
+				t.Error(err)
+			}
+		})
+		return func(name, input string) string {
+			t.Helper()
+			output, err := toolsExecutor.CallTool(ctx, name, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return output
+		}
+	}
+
+	// The empty directory is indexed without the embeddings, leaving only the described collection
+	call := newExecutor()
+	call(IndexRepositoryDefinition.Name, `{"collection": "docs", "path": ".", "description": "project docs"}`)
+	if output := call(ListCollectionsDefinition.Name, `{}`); !strings.Contains(output, "- docs (0 documents): project docs") {
+		t.Errorf("collections %q, want the indexed collection", output)
+	}
+
+	// The other executor has its own store
+	call = newExecutor()
+	if output := call(ListCollectionsDefinition.Name, `{}`); output != "No collections found." {
+		t.Errorf("collections %q, want none of the other executor", output)
+	}
+}
@@ -0,0 +1,29 @@
+// Package list is the generic list.
+package list
+
+import "cmp"
+
+// List keeps the items in order.
+type List[T any] struct {
+	items []T
+}
+
+// Push appends the item.
+func (l *List[T]) Push(item T) {
+	l.items = append(l.items, item)
+}
+
+// Pair is the key and the value.
+type Pair[K cmp.Ordered, V any] struct {
+	Key   K
+	Value V
+}
+
+// Less compares the keys.
+func (p Pair[K, V]) Less(other Pair[K, V]) bool {
+	return p.Key < other.Key
+}
+
+func New[T any]() *List[T] {
+	return &List[T]{}
+}
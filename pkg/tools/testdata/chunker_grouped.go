@@ -0,0 +1,26 @@
+package io
+
+type (
+	// Reader reads the bytes.
+	Reader interface {
+		Read(p []byte) (int, error)
+	}
+
+	// Writer writes the bytes.
+	Writer interface {
+		Write(p []byte) (int, error)
+	}
+)
+
+// Seeker moves the offset.
+type (
+	Seeker interface {
+		Seek(offset int64, whence int) (int64, error)
+	}
+)
+
+const (
+	// SeekStart is relative to the start.
+	SeekStart = 0
+	SeekEnd   = 2
+)
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultToolOutputLimit is the maximum size in bytes of the tool result returned to the model.
+const DefaultToolOutputLimit = 16 * 1024
+
+var ReadToolOutputDefinition = llms.FunctionDefinition{
+	Name: tools.ReadToolOutputName,
+	Description: `Reads the full output of a truncated tool result by pages.
+Use the handle from the "bytes omitted" marker of the truncated result and the offset from the previous page.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"handle": map[string]any{
+				"type":        "string",
+				"description": "the output handle from the truncated result",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "the byte offset to read from, 0 by default",
+			},
+			"length": map[string]any{
+				"type":        "integer",
+				"description": "optional maximum number of bytes to read",
+			},
+		},
+	},
+}
+
+type ReadToolOutputArgs struct {
+	Handle string `json:"handle"`
+	Offset int    `json:"offset,omitempty"`
+	Length int    `json:"length,omitempty"`
+}
+
+// ReadToolOutputTool pages through the full tool outputs kept by the tools executor.
+type ReadToolOutputTool struct {
+	Outputs  *tools.OutputStore
+	PageSize int
+}
+
+func (t ReadToolOutputTool) Call(ctx context.Context, input string) (string, error) {
+	args := ReadToolOutputArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+
+	output, ok := t.Outputs.Get(strings.TrimSpace(args.Handle))
+	if !ok {
+		return "", fmt.Errorf("no such output %q, it may have expired", args.Handle)
+	}
+
+	length := args.Length
+	if length <= 0 || length > t.PageSize {
+		length = t.PageSize
+	}
+	page, next := tools.OutputPage(output, args.Offset, length)
+	if page == "" {
+		return "", fmt.Errorf("offset %d is out of the output size %d", args.Offset, len(output))
+	}
+
+	end := len(output)
+	if next != -1 {
+		end = next
+	}
+	header := fmt.Sprintf("[bytes %d-%d of %d]\n", end-len(page), end, len(output))
+	footer := "\n[end of output]"
+	if next != -1 {
+		footer = fmt.Sprintf("\n[continue with offset %d]", next)
+	}
+	return header + page + footer, nil
+}
+
+// withOutputReader adds the readToolOutput tool to the executor with limited tools output.
+func withOutputReader(toolsExecutor *tools.ToolsExecutor, pageSize int) {
+	for _, tool := range toolsExecutor.Tools {
+		if tool.OutputLimit <= 0 {
+			continue
+		}
+
+		readToolOutputTool := ReadToolOutputTool{
+			Outputs:  toolsExecutor.Outputs,
+			PageSize: pageSize,
+		}
+		toolsExecutor.Tools[ReadToolOutputDefinition.Name] = &tools.ToolData{
+			Definition: ReadToolOutputDefinition,
+			Call:       readToolOutputTool.Call,
+		}
+		return
+	}
+}
@@ -3,9 +3,12 @@ package tools
 import (
 	"context"
 	"slices"
+	"sync"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/rs/zerolog/log"
 )
 
 type ExecutorOption func(*ExecutorOptions)
@@ -18,34 +21,53 @@ var globalToolsRegistry = []func(context.Context, config.Config) (*tools.ToolDat
 var globalToolsExecutor *tools.ToolsExecutor
 
 func NewToolsExecutor(ctx context.Context, cfg config.Config, opts ...ExecutorOption) (*tools.ToolsExecutor, error) {
-	toolsExecutor := tools.ToolsExecutor{}
+	toolsExecutor := tools.ToolsExecutor{
+		Outputs: &tools.OutputStore{Size: cfg.ToolOutputStoreSize},
+	}
 	tools := map[string]*tools.ToolData{}
 	options := ExecutorOptions{}
 
 	for _, opt := range opts {
 		opt(&options)
 	}
+	if cfg.ToolOutputLimit == 0 {
+		cfg.ToolOutputLimit = DefaultToolOutputLimit
+	}
 
+	shared := &sharedResources{values: map[string]any{}}
+	ctx = context.WithValue(ctx, sharedResourcesKey{}, shared)
+	if err := shareWorkspace(ctx, &cfg, options.enabled); err != nil {
+		return nil, err
+	}
+	toolsExecutor.Tools = tools
 	for _, toolInit := range globalToolsRegistry {
 		tool, err := toolInit(ctx, cfg)
 		if err != nil {
+			toolsExecutor.SharedCleanup = shared.cleanups
+			if cleanupErr := toolsExecutor.Cleanup(); cleanupErr != nil {
+				log.Warn().Err(cleanupErr).Msg("tools cleanup after the init error")
+			}
 			return nil, err
 		}
 		if tool == nil {
 			continue
 		}
 
-		if len(options.ToolsWhitelist) > 0 &&
-			!slices.Contains(
-				options.ToolsWhitelist,
-				tool.Definition.Name,
-			) {
+		if !options.enabled(tool.Definition.Name) {
+			if tool.Cleanup != nil {
+				if err := tool.Cleanup(); err != nil {
+					log.Warn().Err(err).Msgf("cleanup of the tool %s not in the whitelist", tool.Definition.Name)
+				}
+			}
 			continue
 		}
 
 		tools[tool.Definition.Name] = tool
 	}
-	toolsExecutor.Tools = tools
+	toolsExecutor.SharedCleanup = shared.cleanups
+
+	// The truncated results refer to the output reader, so it is added regardless of the whitelist
+	withOutputReader(&toolsExecutor, cfg.ToolOutputLimit)
 
 	globalToolsExecutor = &toolsExecutor
 
@@ -57,3 +79,45 @@ func WithToolsWhitelist(tool ...string) ExecutorOption {
 		eo.ToolsWhitelist = append(eo.ToolsWhitelist, tool...)
 	}
 }
+
+// enabled reports whether the tool passes the whitelist.
+func (o ExecutorOptions) enabled(name string) bool {
+	return len(o.ToolsWhitelist) == 0 || slices.Contains(o.ToolsWhitelist, name)
+}
+
+// sharedResources keeps the resources shared by the tools of one executor, such as the response cache.
+type sharedResources struct {
+	mu       sync.Mutex
+	values   map[string]any
+	cleanups []func() error
+}
+
+type sharedResourcesKey struct{}
+
+// sharedResource returns the executor resource of the key, created on the first call.
+// The cleanup of the resource is run by the executor cleanup, so the tools must not close it.
+// Outside of NewToolsExecutor every call creates the new resource, which is never cleaned up.
+func sharedResource[T any](ctx context.Context, key string, create func() (T, func() error, error)) (T, error) {
+	shared, ok := ctx.Value(sharedResourcesKey{}).(*sharedResources)
+	if !ok {
+		value, _, err := create()
+		return value, err
+	}
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if value, ok := shared.values[key]; ok {
+		// The nil interface values are stored too, such as the disabled cache
+		typed, _ := value.(T)
+		return typed, nil
+	}
+	value, cleanup, err := create()
+	if err != nil {
+		return value, err
+	}
+	shared.values[key] = value
+	if cleanup != nil {
+		shared.cleanups = append(shared.cleanups, cleanup)
+	}
+	return value, nil
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSharedResource(t *testing.T) {
+	shared := &sharedResources{values: map[string]any{}}
+	ctx := context.WithValue(context.Background(), sharedResourcesKey{}, shared)
+
+	created, closed := 0, 0
+	create := func() (*MemoryCache, func() error, error) {
+		created++
+		return NewMemoryCache(0), func() error { closed++; return nil }, nil
+	}
+	first, err := sharedResource(ctx, "cache", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sharedResource(ctx, "cache", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || created != 1 {
+		t.Errorf("created %d resources, want one shared", created)
+	}
+	if len(shared.cleanups) != 1 {
+		t.Fatalf("%d cleanups, want 1", len(shared.cleanups))
+	}
+	shared.cleanups[0]()
+	if closed != 1 {
+		t.Errorf("closed %d times, want once", closed)
+	}
+
+	// The disabled cache is kept as nil
+	disabled := func() (ResponseCache, func() error, error) {
+		created++
+		return nil, nil, nil
+	}
+	for range 2 {
+		cache, err := sharedResource(ctx, "disabled", disabled)
+		if err != nil || cache != nil {
+			t.Errorf("cache %v, error %v, want nil", cache, err)
+		}
+	}
+	if created != 2 {
+		t.Errorf("disabled resource created %d times, want once", created-1)
+	}
+}
+
+// countingSearch returns one result per call, counting the calls.
+type countingSearch struct {
+	name  string
+	calls *int
+}
+
+func (s countingSearch) Name() string {
+	return s.name
+}
+
+func (s countingSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	*s.calls++
+	return []SearchResult{{Rank: 1, Title: query, URL: fmt.Sprintf("https://%s.example/%d", s.name, maxResults)}}, nil
+}
+
+func TestDDGSearchCacheKey(t *testing.T) {
+	cache := NewMemoryCache(0)
+	calls := 0
+	search := func(provider string, maxResults int, query string) {
+		t.Helper()
+		tool := DDGSearchTool{
+			Provider:   countingSearch{name: provider, calls: &calls},
+			MaxResults: maxResults,
+			cache:      cache,
+		}
+		if _, err := tool.Call(context.Background(), fmt.Sprintf(`{"query": %q}`, query)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search("a", 5, "Go generics")
+	search("a", 5, "  go   GENERICS ")
+	if calls != 1 {
+		t.Errorf("%d searches of the same query, want the cached result", calls)
+	}
+	search("b", 5, "go generics")
+	search("a", 10, "go generics")
+	if calls != 3 {
+		t.Errorf("%d searches, want the other provider and results number searched again", calls)
+	}
+}
@@ -3,6 +3,9 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Swarmind/libagent/internal/tools"
 	webreader "github.com/Swarmind/libagent/internal/tools/webReader"
@@ -14,7 +17,9 @@ import (
 var WebReaderDefinition = llms.FunctionDefinition{
 	Name: "webReader",
 	Description: `Uses provided valid URL and provides a markdown text converted from html for ease of read.
-		Please be sure to put a valid URL here, you can use LLM tool to extract it from query before using it in this tool.`,
+		Please be sure to put a valid URL here, you can use LLM tool to extract it from query before using it in this tool.
+		By default the whole page is converted, the article mode keeps only its main content with the title, author and date.
+		Long documents can be read in parts with max_chars, continuing from the offset given at the end of the part.`,
 	Parameters: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
@@ -22,15 +27,42 @@ var WebReaderDefinition = llms.FunctionDefinition{
 				"type":        "string",
 				"description": "The valid url to read as text from.",
 			},
+			"mode": map[string]any{
+				"type":        "string",
+				"enum":        []string{webreader.ModeArticle, webreader.ModeFull},
+				"description": "article to keep the main content only, full to read the whole page including navigation",
+			},
+			"selector": map[string]any{
+				"type":        "string",
+				"description": "optional CSS selector of the page elements to read, overrides the mode",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "the character offset to read from, 0 by default",
+			},
+			"max_chars": map[string]any{
+				"type":        "integer",
+				"description": "optional maximum number of characters to read",
+			},
 		},
 	},
 }
 
 type WebReaderArgs struct {
-	URL string `json:"url"`
+	URL      string `json:"url"`
+	Mode     string `json:"mode,omitempty"`
+	Selector string `json:"selector,omitempty"`
+	Offset   int    `json:"offset,omitempty"`
+	MaxChars int    `json:"max_chars,omitempty"`
 }
 
 type WebReaderTool struct {
+	Reader *webreader.Reader
+	// Mode is the extraction mode used when the mode argument is empty, full if empty too
+	Mode string
+	// Cache keeps the read documents by the normalized URL if set
+	Cache    ResponseCache
+	CacheTTL time.Duration
 }
 
 func (t WebReaderTool) Call(ctx context.Context, input string) (string, error) {
@@ -39,7 +71,99 @@ func (t WebReaderTool) Call(ctx context.Context, input string) (string, error) {
 		return "", err
 	}
 
-	return webreader.ProcessUrl(webReaderArgs.URL)
+	mode := webReaderArgs.Mode
+	if mode == "" {
+		mode = t.Mode
+	}
+	if mode == "" {
+		mode = webreader.ModeFull
+	}
+	if mode != webreader.ModeArticle && mode != webreader.ModeFull {
+		return "", fmt.Errorf("unknown mode %q", mode)
+	}
+
+	text, err := t.Read(ctx, webReaderArgs.URL, mode, webReaderArgs.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	if webReaderArgs.Offset == 0 && webReaderArgs.MaxChars <= 0 {
+		return text, nil
+	}
+	chunk, next := webreader.Chunk(text, webReaderArgs.Offset, webReaderArgs.MaxChars)
+	total := utf8.RuneCountInString(text)
+	if chunk == "" {
+		return "", fmt.Errorf("offset %d is out of the document size %d", webReaderArgs.Offset, total)
+	}
+
+	header := fmt.Sprintf("[characters %d-%d of %d]\n", next-utf8.RuneCountInString(chunk), next, total)
+	footer := "\n[end of document]"
+	if next < total {
+		footer = fmt.Sprintf("\n[continue with offset %d]", next)
+	}
+	return header + chunk + footer, nil
+}
+
+// Read returns the rendered document, cached as a whole, so the next parts are read without fetching it again.
+func (t WebReaderTool) Read(ctx context.Context, url, mode, selector string) (string, error) {
+	key := fmt.Sprintf("webReader %s mode=%s selector=%q", NormalizeURL(url), mode, selector)
+	return cachedCall(ctx, t.Cache, t.CacheTTL, key, func() (string, error) {
+		reader := t.Reader
+		if reader == nil {
+			reader = webreader.New(webreader.Options{})
+			defer reader.Close()
+		}
+		doc, err := reader.Read(ctx, url, webreader.ReadOptions{
+			Mode:     mode,
+			Selector: selector,
+		})
+		if err != nil {
+			return "", err
+		}
+		return doc.String(), nil
+	})
+}
+
+// Close shuts down the reader browser, the cache is closed by its owner.
+func (t WebReaderTool) Close() error {
+	if t.Reader == nil {
+		return nil
+	}
+	return t.Reader.Close()
+}
+
+// newWebReaderTool returns the reader tool configured by the web reader settings.
+func newWebReaderTool(ctx context.Context, cfg config.Config) (WebReaderTool, error) {
+	switch cfg.WebReaderMode {
+	case "", webreader.ModeArticle, webreader.ModeFull:
+	default:
+		return WebReaderTool{}, fmt.Errorf("web reader: unknown mode %q", cfg.WebReaderMode)
+	}
+	switch cfg.WebReaderJSRendering {
+	case "", webreader.JSRenderingAuto, webreader.JSRenderingAlways, webreader.JSRenderingNever:
+	default:
+		return WebReaderTool{}, fmt.Errorf("web reader: unknown JS rendering mode %q", cfg.WebReaderJSRendering)
+	}
+
+	cache, err := sharedResponseCache(ctx, cfg)
+	if err != nil {
+		return WebReaderTool{}, fmt.Errorf("web reader: %w", err)
+	}
+
+	return WebReaderTool{
+		Reader: webreader.New(webreader.Options{
+			Timeout:            time.Duration(cfg.WebReaderTimeout) * time.Second,
+			MaxBodySize:        int64(cfg.WebReaderMaxBodySize),
+			MaxRedirects:       cfg.WebReaderMaxRedirects,
+			UserAgent:          cfg.WebReaderUserAgent,
+			JSRendering:        cfg.WebReaderJSRendering,
+			MaxPages:           cfg.WebReaderMaxPages,
+			BrowserIdleTimeout: time.Duration(cfg.WebReaderBrowserIdleTimeout) * time.Second,
+		}),
+		Mode:     cfg.WebReaderMode,
+		Cache:    cache,
+		CacheTTL: time.Duration(cfg.ToolCacheTTL) * time.Second,
+	}, nil
 }
 
 func init() {
@@ -48,11 +172,16 @@ func init() {
 			if cfg.WebReaderDisable {
 				return nil, nil
 			}
-			webReaderTool := WebReaderTool{}
+			webReaderTool, err := newWebReaderTool(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
 
 			return &tools.ToolData{
-				Definition: WebReaderDefinition,
-				Call:       webReaderTool.Call,
+				Definition:  WebReaderDefinition,
+				Call:        webReaderTool.Call,
+				Cleanup:     webReaderTool.Close,
+				OutputLimit: cfg.ToolOutputLimit,
 			}, nil
 		},
 	)
@@ -0,0 +1,371 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Swarmind/libagent/internal/tools"
+	webreader "github.com/Swarmind/libagent/internal/tools/webReader"
+	"github.com/Swarmind/libagent/pkg/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// DefaultResearchResults is the number of the search results read by webResearch.
+	DefaultResearchResults = 3
+	// DefaultResearchMaxChars is the size in characters of the webResearch digest.
+	DefaultResearchMaxChars = 8000
+	// DefaultResearchTimeout bounds the whole webResearch call, the sources not read in time are skipped.
+	DefaultResearchTimeout = time.Minute
+)
+
+// Passages longer than this are cut, so one passage does not take the whole source budget
+const maxPassageChars = 800
+
+var WebResearchDefinition = llms.FunctionDefinition{
+	Name: "webResearch",
+	Description: `Searches the web and reads the top results at once.
+Returns the passages of every page relevant to the query, cited with [n] and followed by the list of the source URLs.
+Use it instead of webSearch followed by webReader calls when the answer needs the content of the pages.`,
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The web search query, also used to select the relevant passages",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": "optional number of the search results to read",
+			},
+		},
+	},
+}
+
+type WebResearchArgs struct {
+	Query      string `json:"query"`
+	MaxResults int    `json:"max_results,omitempty"`
+}
+
+// WebResearchTool runs the web search and reads the top results concurrently through the web reader,
+// returning the cited digest of the passages relevant to the query.
+type WebResearchTool struct {
+	Search SearchProvider
+	Reader WebReaderTool
+	// MaxResults is the default and the maximum number of the read results
+	MaxResults int
+	// MaxChars is the digest budget, split between the sources
+	MaxChars int
+	Timeout  time.Duration
+}
+
+// researchSource is the read search result.
+type researchSource struct {
+	result   SearchResult
+	passages []string
+	err      error
+}
+
+func (t WebResearchTool) Call(ctx context.Context, input string) (string, error) {
+	args := WebResearchArgs{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", fmt.Errorf("empty query")
+	}
+
+	maxResults := t.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultResearchResults
+	}
+	if args.MaxResults > 0 {
+		maxResults = min(args.MaxResults, maxResults)
+	}
+	maxChars := t.MaxChars
+	if maxChars <= 0 {
+		maxChars = DefaultResearchMaxChars
+	}
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+
+	// Search for more results than read, replacing the failed pages
+	results, err := t.Search.Search(ctx, args.Query, maxResults*2)
+	if err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No results found for %q.", args.Query), nil
+	}
+
+	sources := t.read(ctx, args.Query, results, maxResults, maxChars/maxResults)
+	return renderResearch(args.Query, sources), nil
+}
+
+// read fetches the results concurrently until maxResults of them are read,
+// the next results are started in place of the failed ones.
+func (t WebResearchTool) read(ctx context.Context, query string, results []SearchResult, maxResults, budget int) []researchSource {
+	sources := make([]researchSource, len(results))
+	done := make(chan int)
+	next, running, read := 0, 0, 0
+
+	start := func() {
+		i := next
+		next++
+		running++
+		go func() {
+			sources[i] = t.readSource(ctx, query, results[i], budget)
+			done <- i
+		}()
+	}
+	for next < len(results) && running < maxResults {
+		start()
+	}
+	for running > 0 {
+		i := <-done
+		running--
+		if sources[i].err == nil {
+			read++
+		} else {
+			log.Debug().Err(sources[i].err).Msgf("webResearch skip %s", results[i].URL)
+		}
+		if read+running < maxResults && next < len(results) && ctx.Err() == nil {
+			start()
+		}
+	}
+
+	var readSources []researchSource
+	for _, source := range sources[:next] {
+		if source.err == nil && len(readSources) < maxResults {
+			readSources = append(readSources, source)
+		}
+	}
+	// Keep the failures only if nothing was read, to report them
+	if len(readSources) == 0 {
+		return sources[:next]
+	}
+	return readSources
+}
+
+func (t WebResearchTool) readSource(ctx context.Context, query string, result SearchResult, budget int) researchSource {
+	text, err := t.Reader.Read(ctx, result.URL, webreader.ModeArticle, "")
+	if err != nil {
+		return researchSource{result: result, err: err}
+	}
+	passages := relevantPassages(text, query, budget)
+	if len(passages) == 0 {
+		return researchSource{result: result, err: errors.New("no relevant passages")}
+	}
+	return researchSource{result: result, passages: passages}
+}
+
+func renderResearch(query string, sources []researchSource) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Research digest for %q\n\n", query)
+
+	var failed []string
+	n := 0
+	for _, source := range sources {
+		if source.err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", source.result.URL, source.err))
+			continue
+		}
+		n++
+		fmt.Fprintf(&sb, "[%d] %s\n", n, source.result.Title)
+		for _, passage := range source.passages {
+			fmt.Fprintf(&sb, "%s [%d]\n\n", passage, n)
+		}
+	}
+	if n == 0 {
+		sb.WriteString("No sources could be read:\n")
+		for _, line := range failed {
+			sb.WriteString("- " + line + "\n")
+		}
+		return sb.String()
+	}
+
+	sb.WriteString("Sources:\n")
+	n = 0
+	for _, source := range sources {
+		if source.err != nil {
+			continue
+		}
+		n++
+		fmt.Fprintf(&sb, "[%d] %s\n", n, source.result.URL)
+	}
+	return sb.String()
+}
+
+// relevantPassages returns the paragraphs of the text sharing the most terms with the query,
+// in the document order, fitting into the budget characters.
+func relevantPassages(text, query string, budget int) []string {
+	terms := queryTerms(query)
+
+	type passage struct {
+		index int
+		text  string
+		score float64
+	}
+	var passages []passage
+	for i, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		// Skip the headings, the links lists and other short lines
+		if utf8.RuneCountInString(paragraph) < 60 {
+			continue
+		}
+		if score := passageScore(paragraph, terms); score > 0 {
+			passages = append(passages, passage{index: i, text: truncateRunes(paragraph, maxPassageChars), score: score})
+		}
+	}
+	slices.SortStableFunc(passages, func(a, b passage) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+
+	var selected []passage
+	used := 0
+	for _, p := range passages {
+		size := utf8.RuneCountInString(p.text)
+		if used+size > budget && len(selected) > 0 {
+			continue
+		}
+		if used+size > budget {
+			p.text = truncateRunes(p.text, budget)
+			size = budget
+		}
+		selected = append(selected, p)
+		used += size
+	}
+	slices.SortFunc(selected, func(a, b passage) int { return a.index - b.index })
+
+	texts := make([]string, len(selected))
+	for i, p := range selected {
+		texts[i] = p.text
+	}
+	return texts
+}
+
+// passageScore sums the saturated frequencies of the query terms, rewarding the passages covering more of them.
+func passageScore(passage string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, word := range splitWords(passage) {
+		counts[word]++
+	}
+
+	score, matched := 0.0, 0
+	for _, term := range terms {
+		if count := counts[term]; count > 0 {
+			matched++
+			score += 1 + math.Log(float64(count))
+		}
+	}
+	return score * float64(matched) / float64(len(terms))
+}
+
+var researchStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "how": true, "what": true, "why": true, "who": true,
+	"with": true, "from": true, "that": true, "this": true, "does": true, "into": true, "about": true, "which": true,
+}
+
+// queryTerms returns the distinct query words without the short and stop words,
+// or all the distinct words if nothing else is left, such as for "Go 1.22 GC".
+func queryTerms(query string) []string {
+	var terms, words []string
+	for _, word := range splitWords(query) {
+		if slices.Contains(words, word) {
+			continue
+		}
+		words = append(words, word)
+		if utf8.RuneCountInString(word) >= 3 && !researchStopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	if len(terms) == 0 {
+		return words
+	}
+	return terms
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:max(limit-3, 0)])) + "..."
+}
+
+// Close shuts down the reader.
+func (t WebResearchTool) Close() error {
+	return t.Reader.Close()
+}
+
+func init() {
+	globalToolsRegistry = append(globalToolsRegistry,
+		func(ctx context.Context, cfg config.Config) (*tools.ToolData, error) {
+			if cfg.WebResearchDisable {
+				return nil, nil
+			}
+			if cfg.WebResearchMaxResults == 0 {
+				cfg.WebResearchMaxResults = DefaultResearchResults
+			}
+			if cfg.WebResearchMaxChars == 0 {
+				cfg.WebResearchMaxChars = DefaultResearchMaxChars
+			}
+			timeout := DefaultResearchTimeout
+			if cfg.WebResearchTimeout > 0 {
+				timeout = time.Duration(cfg.WebResearchTimeout) * time.Second
+			}
+
+			search, err := NewSearchProvider(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("web research: %w", err)
+			}
+			reader, err := newWebReaderTool(ctx, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("web research: %w", err)
+			}
+
+			webResearchTool := WebResearchTool{
+				Search:     search,
+				Reader:     reader,
+				MaxResults: cfg.WebResearchMaxResults,
+				MaxChars:   cfg.WebResearchMaxChars,
+				Timeout:    timeout,
+			}
+
+			return &tools.ToolData{
+				Definition:  WebResearchDefinition,
+				Call:        webResearchTool.Call,
+				Cleanup:     webResearchTool.Close,
+				OutputLimit: cfg.ToolOutputLimit,
+			}, nil
+		},
+	)
+}
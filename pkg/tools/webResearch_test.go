@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"How does the Go garbage collector work", []string{"garbage", "collector", "work"}},
+		{"Go 1.22 GC", []string{"go", "1", "22", "gc"}},
+		{"the GC and the GC", []string{"the", "gc", "and"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := queryTerms(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("terms %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelevantPassagesShortTerms(t *testing.T) {
+	text := strings.Join([]string{
+		"The release notes of Go 1.22 describe the GC changes, reducing the memory overhead of the heap metadata.",
+		"The unrelated paragraph about the weather, which is long enough to be considered as the passage at all.",
+	}, "\n\n")
+
+	passages := relevantPassages(text, "Go 1.22 GC", 1000)
+	if len(passages) != 1 || !strings.Contains(passages[0], "release notes") {
+		t.Errorf("passages %q, want the Go 1.22 GC paragraph", passages)
+	}
+}